@@ -0,0 +1,336 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	pb "github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userio"
+	"google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/grpc/metadata"
+)
+
+// userFlags adds flags for the fields of a user
+func userFlags(fs *flag.FlagSet, u *pb.User) {
+	fs.StringVar(&u.FirstName, "first-name", "", "first name")
+	fs.StringVar(&u.LastName, "last-name", "", "last name")
+	fs.StringVar(&u.Nickname, "nickname", "", "nickname")
+	fs.StringVar(&u.Email, "email", "", "email address")
+	fs.StringVar(&u.Country, "country", "", "country code")
+}
+
+// userFields maps the flags of userFlags, and the password flag, to the fields of a user
+var userFields = map[string]string{
+	"first-name": "firstName",
+	"last-name":  "lastName",
+	"nickname":   "nickname",
+	"email":      "email",
+	"country":    "country",
+	"password":   "password",
+}
+
+// userID parses a user id argument
+func userID(arg string) (int32, error) {
+	id, err := strconv.ParseInt(arg, 10, 32)
+	if err != nil || id <= 0 {
+		return 0, usageError("invalid user id " + arg)
+	}
+	return int32(id), nil
+}
+
+func add(c *cli, args []string) error {
+	fs := &flag.FlagSet{}
+	u := &pb.User{}
+	userFlags(fs, u)
+	fs.StringVar(&u.Password, "password", "", "password")
+	if err := parse(c, "add", "", args, fs); err != nil {
+		return err
+	}
+	added, err := c.client.Add(c.ctx, u)
+	if err != nil {
+		return err
+	}
+	return c.printUsers(added)
+}
+
+func get(c *cli, args []string) error {
+	fs := &flag.FlagSet{}
+	if err := parse(c, "get", "<id>", args, fs); err != nil {
+		return err
+	}
+	id, err := userID(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	u, err := c.client.Get(c.ctx, &pb.UserId{Id: id})
+	if err != nil {
+		return err
+	}
+	return c.printUsers(u)
+}
+
+func search(c *cli, args []string) error {
+	fs := &flag.FlagSet{}
+	u := &pb.User{}
+	userFlags(fs, u)
+	deleted := fs.Bool("deleted", false, "include deleted users")
+	filter := fs.String("filter", "", `a filter the users must match, e.g. 'country = "GB" AND nickname:"ali*"'`)
+	orderBy := fs.String("order-by", "", "order by id, last_name, nickname or email")
+	limit := fs.Int("limit", 50, "the most users to list, 0 for every user")
+	if err := parse(c, "search", "", args, fs); err != nil {
+		return err
+	}
+	req := &pb.SearchRequest{
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		Nickname:    u.Nickname,
+		Email:       u.Email,
+		Country:     u.Country,
+		ShowDeleted: *deleted,
+		Filter:      *filter,
+		OrderBy:     *orderBy,
+		PageSize:    1000,
+	}
+	users := []*pb.User{}
+	for {
+		if *limit > 0 && *limit-len(users) < int(req.PageSize) {
+			req.PageSize = int32(*limit - len(users))
+		}
+		resp, err := c.client.Search(c.ctx, req)
+		if err != nil {
+			return err
+		}
+		users = append(users, resp.Users...)
+		if resp.NextPageToken == "" || len(users) == *limit {
+			break
+		}
+		req.PageToken = resp.NextPageToken
+	}
+	return c.printUsers(users...)
+}
+
+func modify(c *cli, args []string) error {
+	fs := &flag.FlagSet{}
+	u := &pb.User{}
+	userFlags(fs, u)
+	fs.StringVar(&u.Password, "password", "", "password")
+	fs.StringVar(&u.Etag, "etag", "", "only modify the user if it is still at this etag")
+	if err := parse(c, "modify", "<id>", args, fs); err != nil {
+		return err
+	}
+	id, err := userID(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	u.Id = id
+	mask := &field_mask.FieldMask{}
+	for _, name := range visited(fs) {
+		if field, ok := userFields[name]; ok {
+			mask.Paths = append(mask.Paths, field)
+		}
+	}
+	if len(mask.Paths) == 0 {
+		return usageError("modify needs at least one field to change, e.g. -email")
+	}
+	modified, err := c.client.Update(c.ctx, &pb.UpdateRequest{User: u, UpdateMask: mask})
+	if err != nil {
+		return err
+	}
+	return c.printUsers(modified)
+}
+
+func remove(c *cli, args []string) error {
+	fs := &flag.FlagSet{}
+	etag := fs.String("etag", "", "only delete the user if it is still at this etag, which defaults to its current etag")
+	if err := parse(c, "delete", "<id>", args, fs); err != nil {
+		return err
+	}
+	id, err := userID(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *etag == "" {
+		u, err := c.client.Get(c.ctx, &pb.UserId{Id: id})
+		if err != nil {
+			return err
+		}
+		*etag = u.Etag
+	}
+	_, err = c.client.Delete(c.ctx, &pb.DeleteRequest{Id: id, Etag: *etag})
+	return err
+}
+
+// fileFormat returns the format of a file named on the command line, csv if it has a .csv extension and otherwise jsonl
+func fileFormat(format, path string) (string, error) {
+	if format == "" {
+		format = "jsonl"
+		if strings.HasSuffix(strings.ToLower(path), ".csv") {
+			format = "csv"
+		}
+	}
+	if format != "csv" && format != "jsonl" {
+		return "", usageError("unknown format " + format + ", use csv or jsonl")
+	}
+	return format, nil
+}
+
+func importUsers(c *cli, args []string) error {
+	fs := &flag.FlagSet{}
+	format := fs.String("format", "", "csv or jsonl, which defaults to csv for files named .csv")
+	dryRun := fs.Bool("dry-run", false, "check the users without adding them")
+	if err := parse(c, "import", "<file>", args, fs); err != nil {
+		return err
+	}
+	path := fs.Arg(0)
+	f, err := fileFormat(*format, path)
+	if err != nil {
+		return err
+	}
+	in := c.stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	var reader userio.ImportReader
+	if f == "csv" {
+		if reader, err = userio.NewCSVReader(in); err != nil {
+			return err
+		}
+	} else {
+		reader = userio.NewJSONLReader(in)
+	}
+
+	ctx := c.ctx
+	if *dryRun {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-dry-run", "true")
+	}
+	stream, err := c.client.Import(ctx)
+	if err != nil {
+		return err
+	}
+	// the API numbers users in the order they are sent, so lines maps them back to the lines of the file,
+	// and rows which cannot be read are reported here rather than sent
+	lines := []int{}
+	unread := []*pb.ImportError{}
+	for {
+		u, line, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *userio.RowError
+		if errors.As(err, &rowErr) {
+			unread = append(unread, importError(rowErr))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(u); err != nil {
+			// the reason the stream ended is returned by CloseAndRecv
+			break
+		}
+		lines = append(lines, line)
+	}
+	summary, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	for _, e := range summary.Errors {
+		if int(e.Line) >= 1 && int(e.Line) <= len(lines) {
+			e.Line = int32(lines[e.Line-1])
+		}
+	}
+	summary.Failed += int32(len(unread))
+	summary.Errors = append(summary.Errors, unread...)
+	sort.SliceStable(summary.Errors, func(i, j int) bool { return summary.Errors[i].Line < summary.Errors[j].Line })
+	if err := c.printSummary(summary); err != nil {
+		return err
+	}
+	if summary.Failed > 0 {
+		return errImportFailed
+	}
+	return nil
+}
+
+// importError reports a row of the file which could not be read
+func importError(rowErr *userio.RowError) *pb.ImportError {
+	e := &pb.ImportError{Line: int32(rowErr.Line), Reason: "INVALID_ROW", Message: rowErr.Error()}
+	var err *userio.Error
+	if errors.As(rowErr.Err, &err) {
+		e.Reason, e.Message = err.Reason, err.Message
+	}
+	return e
+}
+
+func exportUsers(c *cli, args []string) error {
+	fs := &flag.FlagSet{}
+	u := &pb.User{}
+	userFlags(fs, u)
+	deleted := fs.Bool("deleted", false, "include deleted users")
+	filter := fs.String("filter", "", `a filter the users must match, e.g. 'country = "GB" AND nickname:"ali*"'`)
+	format := fs.String("format", "", "csv or jsonl, which defaults to csv for files named .csv")
+	fields := fs.String("fields", "", "comma separated fields to export, e.g. id,email; defaults to every field")
+	out := fs.String("o", "-", "the `file` to write, - for standard output")
+	if err := parse(c, "export", "", args, fs); err != nil {
+		return err
+	}
+	f, err := fileFormat(*format, *out)
+	if err != nil {
+		return err
+	}
+	req := &pb.ExportRequest{
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		Nickname:    u.Nickname,
+		Email:       u.Email,
+		Country:     u.Country,
+		ShowDeleted: *deleted,
+		Filter:      *filter,
+	}
+	if *fields != "" {
+		req.ReadMask = &field_mask.FieldMask{Paths: strings.Split(*fields, ",")}
+	}
+	w := c.stdout
+	if *out != "-" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	var writer userio.ExportWriter
+	if f == "csv" {
+		if writer, err = userio.NewCSVWriter(w, req.ReadMask.GetPaths()); err != nil {
+			return err
+		}
+	} else {
+		writer = userio.NewJSONLWriter(w)
+	}
+
+	stream, err := c.client.Export(c.ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		u, err := stream.Recv()
+		if err == io.EOF {
+			return writer.Flush()
+		}
+		if err != nil {
+			return err
+		}
+		if err := writer.Write(u); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Command userctl administers users through the UserService gRPC API.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	pb "github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userio"
+	"github.com/kelseyhightower/envconfig"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// The exit codes of userctl
+const (
+	exitOK = 0
+	// exitFailed is returned when a request fails, or when some users could not be imported
+	exitFailed = 1
+	exitUsage  = 2
+	// exitNotFound is returned when the user does not exist
+	exitNotFound = 3
+	// exitConflict is returned when the email or nickname is in use, or the etag does not match
+	exitConflict = 4
+	// exitUnavailable is returned when the API cannot be reached, and the command may be retried
+	exitUnavailable = 5
+)
+
+const usage = `usage: userctl [flags] <command> [command flags] [arguments]
+
+commands:
+  add       add a user
+  get       get a user by id
+  search    search for users
+  modify    change the fields of a user
+  delete    delete a user
+  import    add the users in a CSV or JSONL file
+  export    write the users matching a search as CSV or JSONL
+
+run userctl <command> -h for the flags of a command
+
+flags, which default to the USERCTL_ environment variables named in brackets:
+`
+
+// config is read from the environment, and overridden by flags
+type config struct {
+	Addr    string        `envconfig:"USERCTL_ADDR" default:"localhost:9000"`
+	Timeout time.Duration `envconfig:"USERCTL_TIMEOUT" default:"30s"`
+	Output  string        `envconfig:"USERCTL_OUTPUT" default:"table"`
+	Actor   string        `envconfig:"USERCTL_ACTOR"`
+}
+
+// command runs a subcommand with the arguments following its name
+type command func(c *cli, args []string) error
+
+var commands = map[string]command{
+	"add":    add,
+	"get":    get,
+	"search": search,
+	"modify": modify,
+	"delete": remove,
+	"import": importUsers,
+	"export": exportUsers,
+}
+
+// cli holds what the commands need to make requests and report the results
+type cli struct {
+	client pb.UserServiceClient
+	ctx    context.Context
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	// output is table or json
+	output string
+}
+
+// usageError is returned when a command is used incorrectly
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// errImportFailed is returned when some of the users could not be imported
+var errImportFailed = errors.New("some users were not imported")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs userctl with the arguments, returning its exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var c config
+	if err := envconfig.Process("", &c); err != nil {
+		fmt.Fprintln(stderr, "userctl:", err)
+		return exitUsage
+	}
+	fs := flag.NewFlagSet("userctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&c.Addr, "addr", c.Addr, "`address` of the UserService gRPC API (USERCTL_ADDR)")
+	fs.DurationVar(&c.Timeout, "timeout", c.Timeout, "time allowed for the command, 0 for no limit, e.g. for large imports (USERCTL_TIMEOUT)")
+	fs.StringVar(&c.Output, "output", c.Output, "output `format`, table or json (USERCTL_OUTPUT)")
+	fs.StringVar(&c.Actor, "actor", c.Actor, "who is making the changes, as recorded in the audit log (USERCTL_ACTOR)")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "userctl: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return exitUsage
+	}
+	if c.Output != "table" && c.Output != "json" {
+		fmt.Fprintf(stderr, "userctl: unknown output format %q, use table or json\n", c.Output)
+		return exitUsage
+	}
+
+	conn, err := grpc.Dial(c.Addr, grpc.WithInsecure())
+	if err != nil {
+		fmt.Fprintln(stderr, "userctl:", err)
+		return exitUnavailable
+	}
+	defer conn.Close()
+	ctx, cancel := context.Background(), func() {}
+	if c.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+	}
+	defer cancel()
+	if c.Actor != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-actor", c.Actor)
+	}
+	return exitCode(cmd(&cli{
+		client: pb.NewUserServiceClient(conn),
+		ctx:    ctx,
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+		output: c.Output,
+	}, fs.Args()[1:]), stderr)
+}
+
+// exitCode reports the error returned by a command, and returns the exit code for it
+func exitCode(err error, stderr io.Writer) int {
+	if err == nil {
+		return exitOK
+	}
+	var usage usageError
+	// files are checked by userio before they are sent
+	var invalid *userio.Error
+	switch {
+	case err == flag.ErrHelp:
+		return exitOK
+	case errors.As(err, &usage):
+		fmt.Fprintln(stderr, "userctl:", err)
+		return exitUsage
+	case errors.As(err, &invalid):
+		fmt.Fprintf(stderr, "userctl: %s (%s)\n", invalid.Message, invalid.Reason)
+		return exitUsage
+	case err == errImportFailed:
+		return exitFailed
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		fmt.Fprintln(stderr, "userctl:", err)
+		return exitFailed
+	}
+	message := st.Message()
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			message += " (" + info.Reason + ")"
+		}
+	}
+	fmt.Fprintf(stderr, "userctl: %s: %s\n", st.Code(), message)
+	switch st.Code() {
+	case codes.NotFound:
+		return exitNotFound
+	case codes.AlreadyExists, codes.FailedPrecondition, codes.Aborted:
+		return exitConflict
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return exitUnavailable
+	}
+	return exitFailed
+}
+
+// parse parses the flags of a command, which takes the named arguments
+func parse(c *cli, name, arguments string, args []string, fs *flag.FlagSet) error {
+	fs.Init(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: userctl %s [flags] %s\n", name, arguments)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return usageError("invalid flags for " + name)
+	}
+	if fs.NArg() != len(strings.Fields(arguments)) {
+		return usageError(strings.TrimSpace(fmt.Sprintf("usage: userctl %s [flags] %s", name, arguments)))
+	}
+	return nil
+}
+
+// visited returns the names of the flags which were set, in order
+func visited(fs *flag.FlagSet) []string {
+	names := []string{}
+	fs.Visit(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+	sort.Strings(names)
+	return names
+}
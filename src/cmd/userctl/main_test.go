@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	pb "github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userhandler"
+	"github.com/beldin0/users/src/userservice"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"gotest.tools/assert"
+)
+
+func TestUserctl(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	pb.RegisterUserServiceServer(server, userhandler.New(context.Background(), userservice.NewMemoryStore()))
+	go server.Serve(lis)
+	defer server.Stop()
+
+	userctl := func(stdin string, args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := run(append([]string{"-addr", lis.Addr().String()}, args...), strings.NewReader(stdin), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	code, out, _ := userctl("", "add", "-nickname", "ken1943", "-email", "ken1943@faceit.com", "-password", "pass", "-country", "US")
+	require.Equal(t, exitOK, code)
+	assert.Equal(t, true, strings.Contains(out, "ken1943@faceit.com"))
+	code, _, errOut := userctl("", "add", "-nickname", "ken1943", "-email", "ken1943@faceit.com", "-password", "pass")
+	assert.Equal(t, exitConflict, code)
+	assert.Equal(t, "userctl: AlreadyExists: email or nickname already in use (DUPLICATE_USER)\n", errOut)
+
+	code, out, _ = userctl("", "-output", "json", "modify", "-last-name", "Thompson", "1")
+	require.Equal(t, exitOK, code)
+	assert.Equal(t, true, strings.Contains(out, `"lastName": "Thompson"`))
+	code, _, _ = userctl("", "modify", "1")
+	assert.Equal(t, exitUsage, code)
+
+	csv := "nickname,email,password\n" +
+		"dennis1941,dennis1941@faceit.com,pass\n" +
+		"ken,ken1943@faceit.com,pass\n" +
+		"\"unterminated\n"
+	code, out, _ = userctl(csv, "import", "-format", "csv", "-")
+	assert.Equal(t, exitFailed, code)
+	require.Equal(t, "imported 1 users, 2 failed\n"+
+		"LINE  REASON          MESSAGE\n"+
+		"3     DUPLICATE_USER  email or nickname already in use\n"+
+		"4     INVALID_ROW     extraneous or missing \" in quoted-field\n", out)
+
+	code, out, _ = userctl("", "export", "-format", "csv", "-fields", "id,nickname")
+	require.Equal(t, exitOK, code)
+	assert.Equal(t, "id,nickname\n1,ken1943\n2,dennis1941\n", out)
+
+	code, out, _ = userctl("", "-output", "json", "search", "-country", "us")
+	require.Equal(t, exitOK, code)
+	assert.Equal(t, true, strings.Contains(out, `"nickname": "ken1943"`))
+
+	code, _, _ = userctl("", "delete", "2")
+	require.Equal(t, exitOK, code)
+	code, _, errOut = userctl("", "get", "2")
+	assert.Equal(t, exitNotFound, code)
+	assert.Equal(t, "userctl: NotFound: user not found (USER_NOT_FOUND)\n", errOut)
+	code, _, _ = userctl("", "get")
+	assert.Equal(t, exitUsage, code)
+	code, _, _ = userctl("", "rename")
+	assert.Equal(t, exitUsage, code)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pb "github.com/beldin0/users/src/user"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+)
+
+// printUsers prints the users as a table, or as JSON: a single user as an object, and otherwise an array
+func (c *cli) printUsers(users ...*pb.User) error {
+	if c.output == "json" {
+		if len(users) == 1 {
+			return c.printJSON(users[0])
+		}
+		m := jsonpb.Marshaler{Indent: "  "}
+		items := make([]string, len(users))
+		for i, u := range users {
+			item, err := m.MarshalToString(u)
+			if err != nil {
+				return err
+			}
+			items[i] = "  " + strings.Replace(item, "\n", "\n  ", -1)
+		}
+		if len(items) == 0 {
+			_, err := fmt.Fprintln(c.stdout, "[]")
+			return err
+		}
+		_, err := fmt.Fprintf(c.stdout, "[\n%s\n]\n", strings.Join(items, ",\n"))
+		return err
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFIRST NAME\tLAST NAME\tNICKNAME\tEMAIL\tCOUNTRY\tETAG\tDELETED")
+	for _, u := range users {
+		deleted := ""
+		if u.DeleteTime != nil {
+			t, _ := ptypes.Timestamp(u.DeleteTime)
+			deleted = t.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			u.Id, u.FirstName, u.LastName, u.Nickname, u.Email, u.Country, u.Etag, deleted)
+	}
+	return w.Flush()
+}
+
+// printSummary prints the outcome of an import, listing the users which were not imported
+func (c *cli) printSummary(summary *pb.ImportSummary) error {
+	if c.output == "json" {
+		return c.printJSON(summary)
+	}
+	verb := "imported"
+	if summary.DryRun {
+		verb = "would import"
+	}
+	fmt.Fprintf(c.stdout, "%s %d users, %d failed\n", verb, summary.Imported, summary.Failed)
+	if len(summary.Errors) == 0 {
+		return nil
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "LINE\tREASON\tMESSAGE")
+	for _, e := range summary.Errors {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", e.Line, e.Reason, e.Message)
+	}
+	return w.Flush()
+}
+
+func (c *cli) printJSON(m proto.Message) error {
+	marshaler := jsonpb.Marshaler{Indent: "  "}
+	if err := marshaler.Marshal(c.stdout, m); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(c.stdout)
+	return err
+}
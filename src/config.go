@@ -1,14 +1,31 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type config struct {
-	Host     string `envconfig:"POSTGRES_HOST" default:"db"`
-	Port     int    `envconfig:"POSTGRES_PORT" default:"5432"`
-	User     string `envconfig:"POSTGRES_USER" default:"postgres"`
-	Password string `envconfig:"POSTGRES_PASSWORD" default:"password"`
-	DBName   string `envconfig:"POSTGRES_DB_NAME" default:"postgres"`
-	SSLMode  bool   `envconfig:"POSTGRES_SSLMODE"`
+	// Driver selects the Store: postgres, sqlite or memory
+	Driver     string `envconfig:"DB_DRIVER" default:"postgres"`
+	SQLitePath string `envconfig:"SQLITE_PATH" default:"users.db"`
+	Host       string `envconfig:"POSTGRES_HOST" default:"db"`
+	Port       int    `envconfig:"POSTGRES_PORT" default:"5432"`
+	User       string `envconfig:"POSTGRES_USER" default:"postgres"`
+	Password   string `envconfig:"POSTGRES_PASSWORD" default:"password"`
+	DBName     string `envconfig:"POSTGRES_DB_NAME" default:"postgres"`
+	SSLMode    bool   `envconfig:"POSTGRES_SSLMODE"`
+	// EventsSink selects where user events are published: stdout, file, nats or none
+	EventsSink    string        `envconfig:"EVENTS_SINK" default:"stdout"`
+	EventsFile    string        `envconfig:"EVENTS_FILE" default:"events.jsonl"`
+	NATSURL       string        `envconfig:"NATS_URL" default:"nats://localhost:4222"`
+	NATSSubject   string        `envconfig:"NATS_SUBJECT_PREFIX" default:"users"`
+	RelayInterval time.Duration `envconfig:"EVENTS_RELAY_INTERVAL" default:"1s"`
+	// WebhookDispatch runs the webhook dispatcher, which should only run on one replica
+	WebhookDispatch    bool          `envconfig:"WEBHOOK_DISPATCH" default:"true"`
+	WebhookInterval    time.Duration `envconfig:"WEBHOOK_INTERVAL" default:"1s"`
+	WebhookBackoff     time.Duration `envconfig:"WEBHOOK_BACKOFF" default:"10s"`
+	WebhookMaxAttempts int           `envconfig:"WEBHOOK_MAX_ATTEMPTS" default:"8"`
 }
 
 func (c config) ConnString() string {
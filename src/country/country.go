@@ -0,0 +1,76 @@
+// Package country holds the ISO 3166-1 countries which users can be from
+package country
+
+import (
+	"sort"
+	"strings"
+)
+
+// Country is an ISO 3166-1 country, with its English name and the UN M49 region it is in
+type Country struct {
+	Alpha2 string
+	Alpha3 string
+	Name   string
+	Region string
+	// names are the other English names the country is known by, e.g. its official name
+	names []string
+}
+
+// aliases are codes in common use which are not the country's ISO 3166-1 code
+var aliases = map[string]string{
+	"UK": "GB",
+}
+
+var (
+	byKey  = map[string]Country{}
+	byName = make([]Country, len(countries))
+)
+
+func init() {
+	for _, c := range countries {
+		byKey[key(c.Alpha2)] = c
+		byKey[key(c.Alpha3)] = c
+		byKey[key(c.Name)] = c
+		for _, name := range c.names {
+			byKey[key(name)] = c
+		}
+	}
+	for alias, code := range aliases {
+		byKey[key(alias)] = byKey[key(code)]
+	}
+	copy(byName, countries)
+	sort.Slice(byName, func(i, j int) bool { return byName[i].Name < byName[j].Name })
+}
+
+func key(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// Lookup finds a country by its alpha-2 or alpha-3 code or by its English name, ignoring case
+func Lookup(s string) (Country, bool) {
+	c, ok := byKey[key(s)]
+	return c, ok
+}
+
+// Canonical returns the alpha-2 code stored for a country given by code or name.
+// Unknown countries are returned in upper case, to be rejected by validation.
+func Canonical(s string) string {
+	if c, ok := Lookup(s); ok {
+		return c.Alpha2
+	}
+	return strings.ToUpper(s)
+}
+
+// All returns every country, ordered by name
+func All() []Country {
+	return append([]Country(nil), byName...)
+}
+
+// Aliases returns the codes in common use which are not ISO 3166-1 codes, with the alpha-2 code of their country
+func Aliases() map[string]string {
+	a := make(map[string]string, len(aliases))
+	for alias, code := range aliases {
+		a[alias] = code
+	}
+	return a
+}
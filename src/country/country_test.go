@@ -0,0 +1,33 @@
+package country
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	for _, s := range []string{"GB", "gbr", " United Kingdom ", "UNITED KINGDOM OF GREAT BRITAIN AND NORTHERN IRELAND", "uk"} {
+		c, ok := Lookup(s)
+		require.True(t, ok, s)
+		require.Equal(t, "GB", c.Alpha2, s)
+		require.Equal(t, "Europe", c.Region)
+	}
+	_, ok := Lookup("Britain")
+	require.False(t, ok)
+	require.Equal(t, "DE", Canonical("Germany"))
+	require.Equal(t, "XX", Canonical("xx"))
+}
+
+func TestAll(t *testing.T) {
+	all := All()
+	require.Equal(t, 249, len(all))
+	for i, c := range all {
+		require.Len(t, c.Alpha2, 2)
+		require.Len(t, c.Alpha3, 3)
+		require.NotEmpty(t, c.Region, c.Name)
+		if i > 0 {
+			require.True(t, all[i-1].Name < c.Name, "countries must be ordered by name")
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package country
+
+// countries is the ISO 3166-1 table, from the Debian iso-codes data, with the UN M49 region of each country.
+// Name is the common English name, and names lists the other English names which are accepted.
+var countries = []Country{
+	{Alpha2: "AD", Alpha3: "AND", Name: "Andorra", Region: "Europe", names: []string{"Principality of Andorra"}},
+	{Alpha2: "AE", Alpha3: "ARE", Name: "United Arab Emirates", Region: "Asia"},
+	{Alpha2: "AF", Alpha3: "AFG", Name: "Afghanistan", Region: "Asia", names: []string{"Islamic Republic of Afghanistan"}},
+	{Alpha2: "AG", Alpha3: "ATG", Name: "Antigua and Barbuda", Region: "Americas"},
+	{Alpha2: "AI", Alpha3: "AIA", Name: "Anguilla", Region: "Americas"},
+	{Alpha2: "AL", Alpha3: "ALB", Name: "Albania", Region: "Europe", names: []string{"Republic of Albania"}},
+	{Alpha2: "AM", Alpha3: "ARM", Name: "Armenia", Region: "Asia", names: []string{"Republic of Armenia"}},
+	{Alpha2: "AO", Alpha3: "AGO", Name: "Angola", Region: "Africa", names: []string{"Republic of Angola"}},
+	{Alpha2: "AQ", Alpha3: "ATA", Name: "Antarctica", Region: "Antarctica"},
+	{Alpha2: "AR", Alpha3: "ARG", Name: "Argentina", Region: "Americas", names: []string{"Argentine Republic"}},
+	{Alpha2: "AS", Alpha3: "ASM", Name: "American Samoa", Region: "Oceania"},
+	{Alpha2: "AT", Alpha3: "AUT", Name: "Austria", Region: "Europe", names: []string{"Republic of Austria"}},
+	{Alpha2: "AU", Alpha3: "AUS", Name: "Australia", Region: "Oceania"},
+	{Alpha2: "AW", Alpha3: "ABW", Name: "Aruba", Region: "Americas"},
+	{Alpha2: "AX", Alpha3: "ALA", Name: "Åland Islands", Region: "Europe"},
+	{Alpha2: "AZ", Alpha3: "AZE", Name: "Azerbaijan", Region: "Asia", names: []string{"Republic of Azerbaijan"}},
+	{Alpha2: "BA", Alpha3: "BIH", Name: "Bosnia and Herzegovina", Region: "Europe", names: []string{"Republic of Bosnia and Herzegovina"}},
+	{Alpha2: "BB", Alpha3: "BRB", Name: "Barbados", Region: "Americas"},
+	{Alpha2: "BD", Alpha3: "BGD", Name: "Bangladesh", Region: "Asia", names: []string{"People's Republic of Bangladesh"}},
+	{Alpha2: "BE", Alpha3: "BEL", Name: "Belgium", Region: "Europe", names: []string{"Kingdom of Belgium"}},
+	{Alpha2: "BF", Alpha3: "BFA", Name: "Burkina Faso", Region: "Africa"},
+	{Alpha2: "BG", Alpha3: "BGR", Name: "Bulgaria", Region: "Europe", names: []string{"Republic of Bulgaria"}},
+	{Alpha2: "BH", Alpha3: "BHR", Name: "Bahrain", Region: "Asia", names: []string{"Kingdom of Bahrain"}},
+	{Alpha2: "BI", Alpha3: "BDI", Name: "Burundi", Region: "Africa", names: []string{"Republic of Burundi"}},
+	{Alpha2: "BJ", Alpha3: "BEN", Name: "Benin", Region: "Africa", names: []string{"Republic of Benin"}},
+	{Alpha2: "BL", Alpha3: "BLM", Name: "Saint Barthélemy", Region: "Americas"},
+	{Alpha2: "BM", Alpha3: "BMU", Name: "Bermuda", Region: "Americas"},
+	{Alpha2: "BN", Alpha3: "BRN", Name: "Brunei Darussalam", Region: "Asia"},
+	{Alpha2: "BO", Alpha3: "BOL", Name: "Bolivia", Region: "Americas", names: []string{"Bolivia, Plurinational State of", "Plurinational State of Bolivia"}},
+	{Alpha2: "BQ", Alpha3: "BES", Name: "Bonaire, Sint Eustatius and Saba", Region: "Americas"},
+	{Alpha2: "BR", Alpha3: "BRA", Name: "Brazil", Region: "Americas", names: []string{"Federative Republic of Brazil"}},
+	{Alpha2: "BS", Alpha3: "BHS", Name: "Bahamas", Region: "Americas", names: []string{"Commonwealth of the Bahamas"}},
+	{Alpha2: "BT", Alpha3: "BTN", Name: "Bhutan", Region: "Asia", names: []string{"Kingdom of Bhutan"}},
+	{Alpha2: "BV", Alpha3: "BVT", Name: "Bouvet Island", Region: "Americas"},
+	{Alpha2: "BW", Alpha3: "BWA", Name: "Botswana", Region: "Africa", names: []string{"Republic of Botswana"}},
+	{Alpha2: "BY", Alpha3: "BLR", Name: "Belarus", Region: "Europe", names: []string{"Republic of Belarus"}},
+	{Alpha2: "BZ", Alpha3: "BLZ", Name: "Belize", Region: "Americas"},
+	{Alpha2: "CA", Alpha3: "CAN", Name: "Canada", Region: "Americas"},
+	{Alpha2: "CC", Alpha3: "CCK", Name: "Cocos (Keeling) Islands", Region: "Oceania"},
+	{Alpha2: "CD", Alpha3: "COD", Name: "Congo, The Democratic Republic of the", Region: "Africa"},
+	{Alpha2: "CF", Alpha3: "CAF", Name: "Central African Republic", Region: "Africa"},
+	{Alpha2: "CG", Alpha3: "COG", Name: "Congo", Region: "Africa", names: []string{"Republic of the Congo"}},
+	{Alpha2: "CH", Alpha3: "CHE", Name: "Switzerland", Region: "Europe", names: []string{"Swiss Confederation"}},
+	{Alpha2: "CI", Alpha3: "CIV", Name: "Côte d'Ivoire", Region: "Africa", names: []string{"Republic of Côte d'Ivoire"}},
+	{Alpha2: "CK", Alpha3: "COK", Name: "Cook Islands", Region: "Oceania"},
+	{Alpha2: "CL", Alpha3: "CHL", Name: "Chile", Region: "Americas", names: []string{"Republic of Chile"}},
+	{Alpha2: "CM", Alpha3: "CMR", Name: "Cameroon", Region: "Africa", names: []string{"Republic of Cameroon"}},
+	{Alpha2: "CN", Alpha3: "CHN", Name: "China", Region: "Asia", names: []string{"People's Republic of China"}},
+	{Alpha2: "CO", Alpha3: "COL", Name: "Colombia", Region: "Americas", names: []string{"Republic of Colombia"}},
+	{Alpha2: "CR", Alpha3: "CRI", Name: "Costa Rica", Region: "Americas", names: []string{"Republic of Costa Rica"}},
+	{Alpha2: "CU", Alpha3: "CUB", Name: "Cuba", Region: "Americas", names: []string{"Republic of Cuba"}},
+	{Alpha2: "CV", Alpha3: "CPV", Name: "Cabo Verde", Region: "Africa", names: []string{"Republic of Cabo Verde"}},
+	{Alpha2: "CW", Alpha3: "CUW", Name: "Curaçao", Region: "Americas"},
+	{Alpha2: "CX", Alpha3: "CXR", Name: "Christmas Island", Region: "Oceania"},
+	{Alpha2: "CY", Alpha3: "CYP", Name: "Cyprus", Region: "Asia", names: []string{"Republic of Cyprus"}},
+	{Alpha2: "CZ", Alpha3: "CZE", Name: "Czechia", Region: "Europe", names: []string{"Czech Republic"}},
+	{Alpha2: "DE", Alpha3: "DEU", Name: "Germany", Region: "Europe", names: []string{"Federal Republic of Germany"}},
+	{Alpha2: "DJ", Alpha3: "DJI", Name: "Djibouti", Region: "Africa", names: []string{"Republic of Djibouti"}},
+	{Alpha2: "DK", Alpha3: "DNK", Name: "Denmark", Region: "Europe", names: []string{"Kingdom of Denmark"}},
+	{Alpha2: "DM", Alpha3: "DMA", Name: "Dominica", Region: "Americas", names: []string{"Commonwealth of Dominica"}},
+	{Alpha2: "DO", Alpha3: "DOM", Name: "Dominican Republic", Region: "Americas"},
+	{Alpha2: "DZ", Alpha3: "DZA", Name: "Algeria", Region: "Africa", names: []string{"People's Democratic Republic of Algeria"}},
+	{Alpha2: "EC", Alpha3: "ECU", Name: "Ecuador", Region: "Americas", names: []string{"Republic of Ecuador"}},
+	{Alpha2: "EE", Alpha3: "EST", Name: "Estonia", Region: "Europe", names: []string{"Republic of Estonia"}},
+	{Alpha2: "EG", Alpha3: "EGY", Name: "Egypt", Region: "Africa", names: []string{"Arab Republic of Egypt"}},
+	{Alpha2: "EH", Alpha3: "ESH", Name: "Western Sahara", Region: "Africa"},
+	{Alpha2: "ER", Alpha3: "ERI", Name: "Eritrea", Region: "Africa", names: []string{"the State of Eritrea"}},
+	{Alpha2: "ES", Alpha3: "ESP", Name: "Spain", Region: "Europe", names: []string{"Kingdom of Spain"}},
+	{Alpha2: "ET", Alpha3: "ETH", Name: "Ethiopia", Region: "Africa", names: []string{"Federal Democratic Republic of Ethiopia"}},
+	{Alpha2: "FI", Alpha3: "FIN", Name: "Finland", Region: "Europe", names: []string{"Republic of Finland"}},
+	{Alpha2: "FJ", Alpha3: "FJI", Name: "Fiji", Region: "Oceania", names: []string{"Republic of Fiji"}},
+	{Alpha2: "FK", Alpha3: "FLK", Name: "Falkland Islands (Malvinas)", Region: "Americas"},
+	{Alpha2: "FM", Alpha3: "FSM", Name: "Micronesia, Federated States of", Region: "Oceania", names: []string{"Federated States of Micronesia"}},
+	{Alpha2: "FO", Alpha3: "FRO", Name: "Faroe Islands", Region: "Europe"},
+	{Alpha2: "FR", Alpha3: "FRA", Name: "France", Region: "Europe", names: []string{"French Republic"}},
+	{Alpha2: "GA", Alpha3: "GAB", Name: "Gabon", Region: "Africa", names: []string{"Gabonese Republic"}},
+	{Alpha2: "GB", Alpha3: "GBR", Name: "United Kingdom", Region: "Europe", names: []string{"United Kingdom of Great Britain and Northern Ireland"}},
+	{Alpha2: "GD", Alpha3: "GRD", Name: "Grenada", Region: "Americas"},
+	{Alpha2: "GE", Alpha3: "GEO", Name: "Georgia", Region: "Asia"},
+	{Alpha2: "GF", Alpha3: "GUF", Name: "French Guiana", Region: "Americas"},
+	{Alpha2: "GG", Alpha3: "GGY", Name: "Guernsey", Region: "Europe"},
+	{Alpha2: "GH", Alpha3: "GHA", Name: "Ghana", Region: "Africa", names: []string{"Republic of Ghana"}},
+	{Alpha2: "GI", Alpha3: "GIB", Name: "Gibraltar", Region: "Europe"},
+	{Alpha2: "GL", Alpha3: "GRL", Name: "Greenland", Region: "Americas"},
+	{Alpha2: "GM", Alpha3: "GMB", Name: "Gambia", Region: "Africa", names: []string{"Republic of the Gambia"}},
+	{Alpha2: "GN", Alpha3: "GIN", Name: "Guinea", Region: "Africa", names: []string{"Republic of Guinea"}},
+	{Alpha2: "GP", Alpha3: "GLP", Name: "Guadeloupe", Region: "Americas"},
+	{Alpha2: "GQ", Alpha3: "GNQ", Name: "Equatorial Guinea", Region: "Africa", names: []string{"Republic of Equatorial Guinea"}},
+	{Alpha2: "GR", Alpha3: "GRC", Name: "Greece", Region: "Europe", names: []string{"Hellenic Republic"}},
+	{Alpha2: "GS", Alpha3: "SGS", Name: "South Georgia and the South Sandwich Islands", Region: "Americas"},
+	{Alpha2: "GT", Alpha3: "GTM", Name: "Guatemala", Region: "Americas", names: []string{"Republic of Guatemala"}},
+	{Alpha2: "GU", Alpha3: "GUM", Name: "Guam", Region: "Oceania"},
+	{Alpha2: "GW", Alpha3: "GNB", Name: "Guinea-Bissau", Region: "Africa", names: []string{"Republic of Guinea-Bissau"}},
+	{Alpha2: "GY", Alpha3: "GUY", Name: "Guyana", Region: "Americas", names: []string{"Republic of Guyana"}},
+	{Alpha2: "HK", Alpha3: "HKG", Name: "Hong Kong", Region: "Asia", names: []string{"Hong Kong Special Administrative Region of China"}},
+	{Alpha2: "HM", Alpha3: "HMD", Name: "Heard Island and McDonald Islands", Region: "Oceania"},
+	{Alpha2: "HN", Alpha3: "HND", Name: "Honduras", Region: "Americas", names: []string{"Republic of Honduras"}},
+	{Alpha2: "HR", Alpha3: "HRV", Name: "Croatia", Region: "Europe", names: []string{"Republic of Croatia"}},
+	{Alpha2: "HT", Alpha3: "HTI", Name: "Haiti", Region: "Americas", names: []string{"Republic of Haiti"}},
+	{Alpha2: "HU", Alpha3: "HUN", Name: "Hungary", Region: "Europe"},
+	{Alpha2: "ID", Alpha3: "IDN", Name: "Indonesia", Region: "Asia", names: []string{"Republic of Indonesia"}},
+	{Alpha2: "IE", Alpha3: "IRL", Name: "Ireland", Region: "Europe"},
+	{Alpha2: "IL", Alpha3: "ISR", Name: "Israel", Region: "Asia", names: []string{"State of Israel"}},
+	{Alpha2: "IM", Alpha3: "IMN", Name: "Isle of Man", Region: "Europe"},
+	{Alpha2: "IN", Alpha3: "IND", Name: "India", Region: "Asia", names: []string{"Republic of India"}},
+	{Alpha2: "IO", Alpha3: "IOT", Name: "British Indian Ocean Territory", Region: "Africa"},
+	{Alpha2: "IQ", Alpha3: "IRQ", Name: "Iraq", Region: "Asia", names: []string{"Republic of Iraq"}},
+	{Alpha2: "IR", Alpha3: "IRN", Name: "Iran", Region: "Asia", names: []string{"Iran, Islamic Republic of", "Islamic Republic of Iran"}},
+	{Alpha2: "IS", Alpha3: "ISL", Name: "Iceland", Region: "Europe", names: []string{"Republic of Iceland"}},
+	{Alpha2: "IT", Alpha3: "ITA", Name: "Italy", Region: "Europe", names: []string{"Italian Republic"}},
+	{Alpha2: "JE", Alpha3: "JEY", Name: "Jersey", Region: "Europe"},
+	{Alpha2: "JM", Alpha3: "JAM", Name: "Jamaica", Region: "Americas"},
+	{Alpha2: "JO", Alpha3: "JOR", Name: "Jordan", Region: "Asia", names: []string{"Hashemite Kingdom of Jordan"}},
+	{Alpha2: "JP", Alpha3: "JPN", Name: "Japan", Region: "Asia"},
+	{Alpha2: "KE", Alpha3: "KEN", Name: "Kenya", Region: "Africa", names: []string{"Republic of Kenya"}},
+	{Alpha2: "KG", Alpha3: "KGZ", Name: "Kyrgyzstan", Region: "Asia", names: []string{"Kyrgyz Republic"}},
+	{Alpha2: "KH", Alpha3: "KHM", Name: "Cambodia", Region: "Asia", names: []string{"Kingdom of Cambodia"}},
+	{Alpha2: "KI", Alpha3: "KIR", Name: "Kiribati", Region: "Oceania", names: []string{"Republic of Kiribati"}},
+	{Alpha2: "KM", Alpha3: "COM", Name: "Comoros", Region: "Africa", names: []string{"Union of the Comoros"}},
+	{Alpha2: "KN", Alpha3: "KNA", Name: "Saint Kitts and Nevis", Region: "Americas"},
+	{Alpha2: "KP", Alpha3: "PRK", Name: "North Korea", Region: "Asia", names: []string{"Korea, Democratic People's Republic of", "Democratic People's Republic of Korea"}},
+	{Alpha2: "KR", Alpha3: "KOR", Name: "South Korea", Region: "Asia", names: []string{"Korea, Republic of"}},
+	{Alpha2: "KW", Alpha3: "KWT", Name: "Kuwait", Region: "Asia", names: []string{"State of Kuwait"}},
+	{Alpha2: "KY", Alpha3: "CYM", Name: "Cayman Islands", Region: "Americas"},
+	{Alpha2: "KZ", Alpha3: "KAZ", Name: "Kazakhstan", Region: "Asia", names: []string{"Republic of Kazakhstan"}},
+	{Alpha2: "LA", Alpha3: "LAO", Name: "Laos", Region: "Asia", names: []string{"Lao People's Democratic Republic"}},
+	{Alpha2: "LB", Alpha3: "LBN", Name: "Lebanon", Region: "Asia", names: []string{"Lebanese Republic"}},
+	{Alpha2: "LC", Alpha3: "LCA", Name: "Saint Lucia", Region: "Americas"},
+	{Alpha2: "LI", Alpha3: "LIE", Name: "Liechtenstein", Region: "Europe", names: []string{"Principality of Liechtenstein"}},
+	{Alpha2: "LK", Alpha3: "LKA", Name: "Sri Lanka", Region: "Asia", names: []string{"Democratic Socialist Republic of Sri Lanka"}},
+	{Alpha2: "LR", Alpha3: "LBR", Name: "Liberia", Region: "Africa", names: []string{"Republic of Liberia"}},
+	{Alpha2: "LS", Alpha3: "LSO", Name: "Lesotho", Region: "Africa", names: []string{"Kingdom of Lesotho"}},
+	{Alpha2: "LT", Alpha3: "LTU", Name: "Lithuania", Region: "Europe", names: []string{"Republic of Lithuania"}},
+	{Alpha2: "LU", Alpha3: "LUX", Name: "Luxembourg", Region: "Europe", names: []string{"Grand Duchy of Luxembourg"}},
+	{Alpha2: "LV", Alpha3: "LVA", Name: "Latvia", Region: "Europe", names: []string{"Republic of Latvia"}},
+	{Alpha2: "LY", Alpha3: "LBY", Name: "Libya", Region: "Africa"},
+	{Alpha2: "MA", Alpha3: "MAR", Name: "Morocco", Region: "Africa", names: []string{"Kingdom of Morocco"}},
+	{Alpha2: "MC", Alpha3: "MCO", Name: "Monaco", Region: "Europe", names: []string{"Principality of Monaco"}},
+	{Alpha2: "MD", Alpha3: "MDA", Name: "Moldova", Region: "Europe", names: []string{"Moldova, Republic of", "Republic of Moldova"}},
+	{Alpha2: "ME", Alpha3: "MNE", Name: "Montenegro", Region: "Europe"},
+	{Alpha2: "MF", Alpha3: "MAF", Name: "Saint Martin (French part)", Region: "Americas"},
+	{Alpha2: "MG", Alpha3: "MDG", Name: "Madagascar", Region: "Africa", names: []string{"Republic of Madagascar"}},
+	{Alpha2: "MH", Alpha3: "MHL", Name: "Marshall Islands", Region: "Oceania", names: []string{"Republic of the Marshall Islands"}},
+	{Alpha2: "MK", Alpha3: "MKD", Name: "North Macedonia", Region: "Europe", names: []string{"Republic of North Macedonia"}},
+	{Alpha2: "ML", Alpha3: "MLI", Name: "Mali", Region: "Africa", names: []string{"Republic of Mali"}},
+	{Alpha2: "MM", Alpha3: "MMR", Name: "Myanmar", Region: "Asia", names: []string{"Republic of Myanmar"}},
+	{Alpha2: "MN", Alpha3: "MNG", Name: "Mongolia", Region: "Asia"},
+	{Alpha2: "MO", Alpha3: "MAC", Name: "Macao", Region: "Asia", names: []string{"Macao Special Administrative Region of China"}},
+	{Alpha2: "MP", Alpha3: "MNP", Name: "Northern Mariana Islands", Region: "Oceania", names: []string{"Commonwealth of the Northern Mariana Islands"}},
+	{Alpha2: "MQ", Alpha3: "MTQ", Name: "Martinique", Region: "Americas"},
+	{Alpha2: "MR", Alpha3: "MRT", Name: "Mauritania", Region: "Africa", names: []string{"Islamic Republic of Mauritania"}},
+	{Alpha2: "MS", Alpha3: "MSR", Name: "Montserrat", Region: "Americas"},
+	{Alpha2: "MT", Alpha3: "MLT", Name: "Malta", Region: "Europe", names: []string{"Republic of Malta"}},
+	{Alpha2: "MU", Alpha3: "MUS", Name: "Mauritius", Region: "Africa", names: []string{"Republic of Mauritius"}},
+	{Alpha2: "MV", Alpha3: "MDV", Name: "Maldives", Region: "Asia", names: []string{"Republic of Maldives"}},
+	{Alpha2: "MW", Alpha3: "MWI", Name: "Malawi", Region: "Africa", names: []string{"Republic of Malawi"}},
+	{Alpha2: "MX", Alpha3: "MEX", Name: "Mexico", Region: "Americas", names: []string{"United Mexican States"}},
+	{Alpha2: "MY", Alpha3: "MYS", Name: "Malaysia", Region: "Asia"},
+	{Alpha2: "MZ", Alpha3: "MOZ", Name: "Mozambique", Region: "Africa", names: []string{"Republic of Mozambique"}},
+	{Alpha2: "NA", Alpha3: "NAM", Name: "Namibia", Region: "Africa", names: []string{"Republic of Namibia"}},
+	{Alpha2: "NC", Alpha3: "NCL", Name: "New Caledonia", Region: "Oceania"},
+	{Alpha2: "NE", Alpha3: "NER", Name: "Niger", Region: "Africa", names: []string{"Republic of the Niger"}},
+	{Alpha2: "NF", Alpha3: "NFK", Name: "Norfolk Island", Region: "Oceania"},
+	{Alpha2: "NG", Alpha3: "NGA", Name: "Nigeria", Region: "Africa", names: []string{"Federal Republic of Nigeria"}},
+	{Alpha2: "NI", Alpha3: "NIC", Name: "Nicaragua", Region: "Americas", names: []string{"Republic of Nicaragua"}},
+	{Alpha2: "NL", Alpha3: "NLD", Name: "Netherlands", Region: "Europe", names: []string{"Kingdom of the Netherlands"}},
+	{Alpha2: "NO", Alpha3: "NOR", Name: "Norway", Region: "Europe", names: []string{"Kingdom of Norway"}},
+	{Alpha2: "NP", Alpha3: "NPL", Name: "Nepal", Region: "Asia", names: []string{"Federal Democratic Republic of Nepal"}},
+	{Alpha2: "NR", Alpha3: "NRU", Name: "Nauru", Region: "Oceania", names: []string{"Republic of Nauru"}},
+	{Alpha2: "NU", Alpha3: "NIU", Name: "Niue", Region: "Oceania"},
+	{Alpha2: "NZ", Alpha3: "NZL", Name: "New Zealand", Region: "Oceania"},
+	{Alpha2: "OM", Alpha3: "OMN", Name: "Oman", Region: "Asia", names: []string{"Sultanate of Oman"}},
+	{Alpha2: "PA", Alpha3: "PAN", Name: "Panama", Region: "Americas", names: []string{"Republic of Panama"}},
+	{Alpha2: "PE", Alpha3: "PER", Name: "Peru", Region: "Americas", names: []string{"Republic of Peru"}},
+	{Alpha2: "PF", Alpha3: "PYF", Name: "French Polynesia", Region: "Oceania"},
+	{Alpha2: "PG", Alpha3: "PNG", Name: "Papua New Guinea", Region: "Oceania", names: []string{"Independent State of Papua New Guinea"}},
+	{Alpha2: "PH", Alpha3: "PHL", Name: "Philippines", Region: "Asia", names: []string{"Republic of the Philippines"}},
+	{Alpha2: "PK", Alpha3: "PAK", Name: "Pakistan", Region: "Asia", names: []string{"Islamic Republic of Pakistan"}},
+	{Alpha2: "PL", Alpha3: "POL", Name: "Poland", Region: "Europe", names: []string{"Republic of Poland"}},
+	{Alpha2: "PM", Alpha3: "SPM", Name: "Saint Pierre and Miquelon", Region: "Americas"},
+	{Alpha2: "PN", Alpha3: "PCN", Name: "Pitcairn", Region: "Oceania"},
+	{Alpha2: "PR", Alpha3: "PRI", Name: "Puerto Rico", Region: "Americas"},
+	{Alpha2: "PS", Alpha3: "PSE", Name: "Palestine, State of", Region: "Asia", names: []string{"the State of Palestine"}},
+	{Alpha2: "PT", Alpha3: "PRT", Name: "Portugal", Region: "Europe", names: []string{"Portuguese Republic"}},
+	{Alpha2: "PW", Alpha3: "PLW", Name: "Palau", Region: "Oceania", names: []string{"Republic of Palau"}},
+	{Alpha2: "PY", Alpha3: "PRY", Name: "Paraguay", Region: "Americas", names: []string{"Republic of Paraguay"}},
+	{Alpha2: "QA", Alpha3: "QAT", Name: "Qatar", Region: "Asia", names: []string{"State of Qatar"}},
+	{Alpha2: "RE", Alpha3: "REU", Name: "Réunion", Region: "Africa"},
+	{Alpha2: "RO", Alpha3: "ROU", Name: "Romania", Region: "Europe"},
+	{Alpha2: "RS", Alpha3: "SRB", Name: "Serbia", Region: "Europe", names: []string{"Republic of Serbia"}},
+	{Alpha2: "RU", Alpha3: "RUS", Name: "Russian Federation", Region: "Europe"},
+	{Alpha2: "RW", Alpha3: "RWA", Name: "Rwanda", Region: "Africa", names: []string{"Rwandese Republic"}},
+	{Alpha2: "SA", Alpha3: "SAU", Name: "Saudi Arabia", Region: "Asia", names: []string{"Kingdom of Saudi Arabia"}},
+	{Alpha2: "SB", Alpha3: "SLB", Name: "Solomon Islands", Region: "Oceania"},
+	{Alpha2: "SC", Alpha3: "SYC", Name: "Seychelles", Region: "Africa", names: []string{"Republic of Seychelles"}},
+	{Alpha2: "SD", Alpha3: "SDN", Name: "Sudan", Region: "Africa", names: []string{"Republic of the Sudan"}},
+	{Alpha2: "SE", Alpha3: "SWE", Name: "Sweden", Region: "Europe", names: []string{"Kingdom of Sweden"}},
+	{Alpha2: "SG", Alpha3: "SGP", Name: "Singapore", Region: "Asia", names: []string{"Republic of Singapore"}},
+	{Alpha2: "SH", Alpha3: "SHN", Name: "Saint Helena, Ascension and Tristan da Cunha", Region: "Africa"},
+	{Alpha2: "SI", Alpha3: "SVN", Name: "Slovenia", Region: "Europe", names: []string{"Republic of Slovenia"}},
+	{Alpha2: "SJ", Alpha3: "SJM", Name: "Svalbard and Jan Mayen", Region: "Europe"},
+	{Alpha2: "SK", Alpha3: "SVK", Name: "Slovakia", Region: "Europe", names: []string{"Slovak Republic"}},
+	{Alpha2: "SL", Alpha3: "SLE", Name: "Sierra Leone", Region: "Africa", names: []string{"Republic of Sierra Leone"}},
+	{Alpha2: "SM", Alpha3: "SMR", Name: "San Marino", Region: "Europe", names: []string{"Republic of San Marino"}},
+	{Alpha2: "SN", Alpha3: "SEN", Name: "Senegal", Region: "Africa", names: []string{"Republic of Senegal"}},
+	{Alpha2: "SO", Alpha3: "SOM", Name: "Somalia", Region: "Africa", names: []string{"Federal Republic of Somalia"}},
+	{Alpha2: "SR", Alpha3: "SUR", Name: "Suriname", Region: "Americas", names: []string{"Republic of Suriname"}},
+	{Alpha2: "SS", Alpha3: "SSD", Name: "South Sudan", Region: "Africa", names: []string{"Republic of South Sudan"}},
+	{Alpha2: "ST", Alpha3: "STP", Name: "Sao Tome and Principe", Region: "Africa", names: []string{"Democratic Republic of Sao Tome and Principe"}},
+	{Alpha2: "SV", Alpha3: "SLV", Name: "El Salvador", Region: "Americas", names: []string{"Republic of El Salvador"}},
+	{Alpha2: "SX", Alpha3: "SXM", Name: "Sint Maarten (Dutch part)", Region: "Americas"},
+	{Alpha2: "SY", Alpha3: "SYR", Name: "Syria", Region: "Asia", names: []string{"Syrian Arab Republic"}},
+	{Alpha2: "SZ", Alpha3: "SWZ", Name: "Eswatini", Region: "Africa", names: []string{"Kingdom of Eswatini"}},
+	{Alpha2: "TC", Alpha3: "TCA", Name: "Turks and Caicos Islands", Region: "Americas"},
+	{Alpha2: "TD", Alpha3: "TCD", Name: "Chad", Region: "Africa", names: []string{"Republic of Chad"}},
+	{Alpha2: "TF", Alpha3: "ATF", Name: "French Southern Territories", Region: "Africa"},
+	{Alpha2: "TG", Alpha3: "TGO", Name: "Togo", Region: "Africa", names: []string{"Togolese Republic"}},
+	{Alpha2: "TH", Alpha3: "THA", Name: "Thailand", Region: "Asia", names: []string{"Kingdom of Thailand"}},
+	{Alpha2: "TJ", Alpha3: "TJK", Name: "Tajikistan", Region: "Asia", names: []string{"Republic of Tajikistan"}},
+	{Alpha2: "TK", Alpha3: "TKL", Name: "Tokelau", Region: "Oceania"},
+	{Alpha2: "TL", Alpha3: "TLS", Name: "Timor-Leste", Region: "Asia", names: []string{"Democratic Republic of Timor-Leste"}},
+	{Alpha2: "TM", Alpha3: "TKM", Name: "Turkmenistan", Region: "Asia"},
+	{Alpha2: "TN", Alpha3: "TUN", Name: "Tunisia", Region: "Africa", names: []string{"Republic of Tunisia"}},
+	{Alpha2: "TO", Alpha3: "TON", Name: "Tonga", Region: "Oceania", names: []string{"Kingdom of Tonga"}},
+	{Alpha2: "TR", Alpha3: "TUR", Name: "Türkiye", Region: "Asia", names: []string{"Republic of Türkiye"}},
+	{Alpha2: "TT", Alpha3: "TTO", Name: "Trinidad and Tobago", Region: "Americas", names: []string{"Republic of Trinidad and Tobago"}},
+	{Alpha2: "TV", Alpha3: "TUV", Name: "Tuvalu", Region: "Oceania"},
+	{Alpha2: "TW", Alpha3: "TWN", Name: "Taiwan", Region: "Asia", names: []string{"Taiwan, Province of China"}},
+	{Alpha2: "TZ", Alpha3: "TZA", Name: "Tanzania", Region: "Africa", names: []string{"Tanzania, United Republic of", "United Republic of Tanzania"}},
+	{Alpha2: "UA", Alpha3: "UKR", Name: "Ukraine", Region: "Europe"},
+	{Alpha2: "UG", Alpha3: "UGA", Name: "Uganda", Region: "Africa", names: []string{"Republic of Uganda"}},
+	{Alpha2: "UM", Alpha3: "UMI", Name: "United States Minor Outlying Islands", Region: "Oceania"},
+	{Alpha2: "US", Alpha3: "USA", Name: "United States", Region: "Americas", names: []string{"United States of America"}},
+	{Alpha2: "UY", Alpha3: "URY", Name: "Uruguay", Region: "Americas", names: []string{"Eastern Republic of Uruguay"}},
+	{Alpha2: "UZ", Alpha3: "UZB", Name: "Uzbekistan", Region: "Asia", names: []string{"Republic of Uzbekistan"}},
+	{Alpha2: "VA", Alpha3: "VAT", Name: "Holy See (Vatican City State)", Region: "Europe"},
+	{Alpha2: "VC", Alpha3: "VCT", Name: "Saint Vincent and the Grenadines", Region: "Americas"},
+	{Alpha2: "VE", Alpha3: "VEN", Name: "Venezuela", Region: "Americas", names: []string{"Venezuela, Bolivarian Republic of", "Bolivarian Republic of Venezuela"}},
+	{Alpha2: "VG", Alpha3: "VGB", Name: "Virgin Islands, British", Region: "Americas", names: []string{"British Virgin Islands"}},
+	{Alpha2: "VI", Alpha3: "VIR", Name: "Virgin Islands, U.S.", Region: "Americas", names: []string{"Virgin Islands of the United States"}},
+	{Alpha2: "VN", Alpha3: "VNM", Name: "Vietnam", Region: "Asia", names: []string{"Viet Nam", "Socialist Republic of Viet Nam"}},
+	{Alpha2: "VU", Alpha3: "VUT", Name: "Vanuatu", Region: "Oceania", names: []string{"Republic of Vanuatu"}},
+	{Alpha2: "WF", Alpha3: "WLF", Name: "Wallis and Futuna", Region: "Oceania"},
+	{Alpha2: "WS", Alpha3: "WSM", Name: "Samoa", Region: "Oceania", names: []string{"Independent State of Samoa"}},
+	{Alpha2: "YE", Alpha3: "YEM", Name: "Yemen", Region: "Asia", names: []string{"Republic of Yemen"}},
+	{Alpha2: "YT", Alpha3: "MYT", Name: "Mayotte", Region: "Africa"},
+	{Alpha2: "ZA", Alpha3: "ZAF", Name: "South Africa", Region: "Africa", names: []string{"Republic of South Africa"}},
+	{Alpha2: "ZM", Alpha3: "ZMB", Name: "Zambia", Region: "Africa", names: []string{"Republic of Zambia"}},
+	{Alpha2: "ZW", Alpha3: "ZWE", Name: "Zimbabwe", Region: "Africa", names: []string{"Republic of Zimbabwe"}},
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/beldin0/users/src/publisher"
+	"github.com/beldin0/users/src/userservice"
+)
+
+// newRelay returns the Relay publishing user events to the configured sink, or nil if events are not published
+func newRelay(c config, store userservice.Store) (*userservice.Relay, error) {
+	var p userservice.Publisher
+	switch c.EventsSink {
+	case "none":
+		return nil, nil
+	case "stdout":
+		p = publisher.NewWriter(os.Stdout)
+	case "file":
+		w, err := publisher.NewFile(c.EventsFile)
+		if err != nil {
+			return nil, err
+		}
+		p = w
+	case "nats":
+		n, err := publisher.NewNATS(c.NATSURL, c.NATSSubject)
+		if err != nil {
+			return nil, err
+		}
+		p = n
+	default:
+		return nil, fmt.Errorf("unknown EVENTS_SINK %q, expected stdout, file, nats or none", c.EventsSink)
+	}
+	return userservice.NewRelay(store, p, c.RelayInterval), nil
+}
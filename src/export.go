@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	pb "github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userio"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/utilities"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// patternExport matches GET /users:export, as declared for the Export RPC in user.proto
+var patternExport = runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"users"}, "export", runtime.AssumeColonVerbOpt(true)))
+
+// filterExport leaves the format parameter out of the ExportRequest
+var filterExport = utilities.NewDoubleArray([][]string{{"format"}})
+
+// handleExport serves the Export RPC over HTTP, as the gateway does not support streaming in process.
+// ?format=csv returns CSV with a header row, and otherwise a user is returned on each line as JSON.
+// The remaining query parameters are those of the ExportRequest, e.g. ?country=GB&readMask=id,email.
+func handleExport(mux *runtime.ServeMux, server pb.UserServiceServer) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		_, marshaler := runtime.MarshalerForRequest(mux, r)
+		ctx, err := runtime.AnnotateIncomingContext(r.Context(), mux, r)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, marshaler, w, r, err)
+			return
+		}
+		req := &pb.ExportRequest{}
+		if err := runtime.PopulateQueryParameters(req, r.URL.Query(), filterExport); err != nil {
+			runtime.HTTPError(ctx, mux, marshaler, w, r, status.Errorf(codes.InvalidArgument, "%v", err))
+			return
+		}
+		stream := &httpExportStream{
+			ctx:  ctx,
+			w:    w,
+			mask: req.ReadMask.GetPaths(),
+			csv:  r.URL.Query().Get("format") == "csv",
+		}
+		err = server.Export(req, stream)
+		if err == nil {
+			err = stream.flush()
+		}
+		if err != nil {
+			if !stream.started {
+				runtime.HTTPError(ctx, mux, marshaler, w, r, err)
+				return
+			}
+			// abort the response, so that the client does not take a partial export for a complete one
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
+
+// httpExportStream adapts an HTTP response to the server side of an Export stream,
+// writing the users to the response as CSV or JSONL
+type httpExportStream struct {
+	ctx     context.Context
+	w       http.ResponseWriter
+	mask    []string
+	csv     bool
+	writer  userio.ExportWriter
+	started bool
+}
+
+func (s *httpExportStream) Context() context.Context {
+	return s.ctx
+}
+
+// start starts the response before the first user is written, so that errors before then are still
+// returned with their HTTP status
+func (s *httpExportStream) start() error {
+	if s.started {
+		return nil
+	}
+	if s.csv {
+		s.w.Header().Set("Content-Type", "text/csv")
+		writer, err := userio.NewCSVWriter(s.w, s.mask)
+		if err != nil {
+			return err
+		}
+		s.writer = writer
+	} else {
+		s.w.Header().Set("Content-Type", "application/x-ndjson")
+		s.writer = userio.NewJSONLWriter(s.w)
+	}
+	s.started = true
+	return nil
+}
+
+func (s *httpExportStream) Send(u *pb.User) error {
+	if err := s.start(); err != nil {
+		return err
+	}
+	return s.writer.Write(u)
+}
+
+// flush writes the end of the export, including the CSV header if no users were exported
+func (s *httpExportStream) flush() error {
+	if err := s.start(); err != nil {
+		return err
+	}
+	return s.writer.Flush()
+}
+
+// SendHeader does nothing, as the response is only started by the first user
+func (s *httpExportStream) SendHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *httpExportStream) SetHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *httpExportStream) SetTrailer(metadata.MD) {}
+
+func (s *httpExportStream) SendMsg(m interface{}) error {
+	u, ok := m.(*pb.User)
+	if !ok {
+		return fmt.Errorf("unexpected message %T on Export stream", m)
+	}
+	return s.Send(u)
+}
+
+func (s *httpExportStream) RecvMsg(interface{}) error {
+	return errors.New("Export has no messages from the client")
+}
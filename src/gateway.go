@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	pb "github.com/beldin0/users/src/user"
+	"github.com/golang/protobuf/proto"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newGatewayMux returns the mux serving the HTTP gateway, which returns the etag of
+// users as an ETag header and reports etag mismatches as 412 Precondition Failed
+func newGatewayMux() *runtime.ServeMux {
+	runtime.HTTPError = httpError
+	return runtime.NewServeMux(
+		runtime.WithForwardResponseOption(etagHeader),
+		runtime.WithIncomingHeaderMatcher(auditHeaders),
+	)
+}
+
+// auditHeaders forwards the X-Actor and X-Request-Id headers recorded in the audit log,
+// along with the headers the gateway forwards by default
+func auditHeaders(key string) (string, bool) {
+	switch strings.ToLower(key) {
+	case "x-actor", "x-request-id":
+		return strings.ToLower(key), true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// httpError reports errors as the gateway does by default, except that FailedPrecondition
+// is 412 rather than 400, as it is only returned when an etag does not match
+func httpError(ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	if status.Code(err) == codes.FailedPrecondition {
+		w = preconditionFailed{w}
+	}
+	runtime.DefaultHTTPError(ctx, mux, m, w, r, err)
+}
+
+// preconditionFailed overrides the status code written to the response
+type preconditionFailed struct {
+	http.ResponseWriter
+}
+
+func (w preconditionFailed) WriteHeader(int) {
+	w.ResponseWriter.WriteHeader(http.StatusPreconditionFailed)
+}
+
+// etagHeader sets the ETag header of responses containing a single user
+func etagHeader(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+	if u, ok := resp.(*pb.User); ok && u.Etag != "" {
+		w.Header().Set("ETag", strconv.Quote(u.Etag))
+	}
+	return nil
+}
@@ -6,17 +6,40 @@ import (
 	"time"
 
 	"github.com/beldin0/users/src/logging"
+	"google.golang.org/grpc"
 )
 
-func gracefulShutdown(mainCtx context.Context, wait chan os.Signal, shutdown func(context.Context) error) {
+func gracefulShutdown(mainCtx context.Context, wait chan os.Signal, shutdowns ...func(context.Context) error) {
 	logger := logging.NewLogger()
 	select {
 	case <-wait:
 	case <-mainCtx.Done():
 	}
 	logger.Info("shutting down")
-	ctx, _ := context.WithTimeout(context.Background(), time.Second)
-	if err := shutdown(ctx); err != nil {
-		logger.Fatal("failed shutting down gracefully")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, shutdown := range shutdowns {
+		if err := shutdown(ctx); err != nil {
+			logger.Fatal("failed shutting down gracefully")
+		}
+	}
+}
+
+// grpcShutdown adapts a grpc.Server to the shutdown signature used by http.Server,
+// forcing the server to stop if the graceful stop does not complete before ctx expires
+func grpcShutdown(s *grpc.Server) func(context.Context) error {
+	return func(ctx context.Context) error {
+		stopped := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			s.Stop()
+			return ctx.Err()
+		}
 	}
 }
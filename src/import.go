@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	pb "github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userio"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+// patternImport matches POST /users:import, as declared for the Import RPC in user.proto
+var patternImport = runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"users"}, "import", runtime.AssumeColonVerbOpt(true)))
+
+// handleImport serves the Import RPC over HTTP, as the gateway does not support streaming in process.
+// The body is CSV with a header row when its Content-Type is text/csv, and otherwise a JSON user on each line.
+// Errors name the line of the body they were found on, and ?dryRun=true checks the users without storing them.
+func handleImport(mux *runtime.ServeMux, server pb.UserServiceServer) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		_, marshaler := runtime.MarshalerForRequest(mux, r)
+		ctx, err := runtime.AnnotateIncomingContext(r.Context(), mux, r)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, marshaler, w, r, err)
+			return
+		}
+		if r.URL.Query().Get("dryRun") == "true" {
+			md, _ := metadata.FromIncomingContext(ctx)
+			md = metadata.Join(md, metadata.Pairs("x-dry-run", "true"))
+			ctx = metadata.NewIncomingContext(ctx, md)
+		}
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		stream := &httpImportStream{
+			ctx:       ctx,
+			w:         w,
+			marshaler: marshaler,
+			body:      r.Body,
+			csv:       mediaType == "text/csv",
+		}
+		if err := server.Import(stream); err != nil {
+			runtime.HTTPError(ctx, mux, marshaler, w, r, err)
+		}
+	}
+}
+
+// httpImportStream adapts an HTTP request to the server side of an Import stream,
+// reading the users from the body as an ImportReader so that errors name their line
+type httpImportStream struct {
+	ctx       context.Context
+	w         http.ResponseWriter
+	marshaler runtime.Marshaler
+	body      io.Reader
+	csv       bool
+	reader    userio.ImportReader
+}
+
+func (s *httpImportStream) Context() context.Context {
+	return s.ctx
+}
+
+// Read returns the next user in the body, reading the CSV header first
+func (s *httpImportStream) Read() (*pb.User, int, error) {
+	if s.reader == nil {
+		if !s.csv {
+			s.reader = userio.NewJSONLReader(s.body)
+		} else {
+			reader, err := userio.NewCSVReader(s.body)
+			if err != nil {
+				return nil, 0, err
+			}
+			s.reader = reader
+		}
+	}
+	return s.reader.Read()
+}
+
+func (s *httpImportStream) SendAndClose(summary *pb.ImportSummary) error {
+	data, err := s.marshaler.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	s.w.Header().Set("Content-Type", s.marshaler.ContentType())
+	_, err = s.w.Write(data)
+	return err
+}
+
+func (s *httpImportStream) Recv() (*pb.User, error) {
+	u, _, err := s.Read()
+	return u, err
+}
+
+func (s *httpImportStream) SetHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *httpImportStream) SendHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *httpImportStream) SetTrailer(metadata.MD) {}
+
+func (s *httpImportStream) SendMsg(m interface{}) error {
+	summary, ok := m.(*pb.ImportSummary)
+	if !ok {
+		return fmt.Errorf("unexpected message %T on Import stream", m)
+	}
+	return s.SendAndClose(summary)
+}
+
+func (s *httpImportStream) RecvMsg(interface{}) error {
+	return errors.New("Import messages are read from the body")
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,10 +12,10 @@ import (
 	"github.com/beldin0/users/src/logging"
 	pb "github.com/beldin0/users/src/user"
 	"github.com/beldin0/users/src/userhandler"
-	"github.com/grpc-ecosystem/grpc-gateway/runtime"
-	"github.com/jmoiron/sqlx"
+	"github.com/beldin0/users/src/userservice"
 	"github.com/kelseyhightower/envconfig"
-	_ "github.com/lib/pq"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
 )
 
 const defaultPort = 8080
@@ -24,27 +25,77 @@ func main() {
 	logger := logging.NewLogger()
 	var c config
 	err := envconfig.Process("", &c)
-	db, err := sqlx.Connect("postgres", c.ConnString())
 	if err != nil {
 		logger.Sugar().
 			With("error", err).
-			With("connection_string", c.ConnString()).
-			Fatal("problem connecting to database")
+			Fatal("problem reading configuration")
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	if run(ctx, db) != http.ErrServerClosed {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		db, err := openDB(c)
+		if err != nil {
+			logger.Sugar().
+				With("error", err).
+				With("driver", c.Driver).
+				Fatal("problem connecting to database")
+		}
+		if err := migrate(ctx, db, os.Args[2:]); err != nil {
+			logger.Sugar().With("error", err).Fatal("problem migrating database")
+		}
+		return
+	}
+
+	store, err := newStore(ctx, c)
+	if err != nil {
+		logger.Sugar().
+			With("error", err).
+			With("driver", c.Driver).
+			Fatal("problem setting up storage")
+	}
+
+	relay, err := newRelay(c, store)
+	if err != nil {
+		logger.Sugar().
+			With("error", err).
+			With("sink", c.EventsSink).
+			Fatal("problem setting up event publishing")
+	}
+
+	workers := []worker{}
+	if relay != nil {
+		workers = append(workers, relay)
+	}
+	if c.WebhookDispatch {
+		workers = append(workers, userservice.NewDispatcher(store, c.WebhookInterval, c.WebhookBackoff, c.WebhookMaxAttempts))
+	}
+
+	if err := run(ctx, store, workers...); err != http.ErrServerClosed {
 		logger.Sugar().With("error", err).Fatal("problem with server")
 	}
 }
 
-func run(ctx context.Context, db *sqlx.DB) error {
+// worker runs in the background alongside the servers, until ctx is done
+type worker interface {
+	Run(ctx context.Context) error
+}
+
+// run serves the API until it is shut down, running the workers alongside it, e.g. to relay user events
+func run(ctx context.Context, store userservice.Store, workers ...worker) error {
 	logger := logging.NewLogger()
+	// ending Watch streams first lets the servers shut down gracefully
+	watchCtx, stopWatches := context.WithCancel(ctx)
+	defer stopWatches()
+	handler := userhandler.New(watchCtx, store)
 
-	mux := runtime.NewServeMux()
-	err := pb.RegisterUserServiceHandlerServer(ctx, mux, userhandler.New(db))
+	mux := newGatewayMux()
+	// the first matching handler serves a request, so these replace the generated streaming handlers
+	mux.Handle(http.MethodGet, patternWatch, handleWatch(mux, handler))
+	mux.Handle(http.MethodPost, patternImport, handleImport(mux, handler))
+	mux.Handle(http.MethodGet, patternExport, handleExport(mux, handler))
+	err := pb.RegisterUserServiceHandlerServer(ctx, mux, handler)
 	if err != nil {
 		return err
 	}
@@ -54,13 +105,38 @@ func run(ctx context.Context, db *sqlx.DB) error {
 		Handler: mux,
 	}
 
+	lis, err := net.Listen("tcp", fmt.Sprint(":", grpcPort))
+	if err != nil {
+		return err
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterUserServiceServer(grpcServer, handler)
+
+	// Either server failing brings the other one down with it
+	g, ctx := errgroup.WithContext(ctx)
+
 	// Prepare for graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
-	go gracefulShutdown(ctx, quit, server.Shutdown)
+	go gracefulShutdown(ctx, quit, func(context.Context) error {
+		stopWatches()
+		return nil
+	}, server.Shutdown, grpcShutdown(grpcServer))
 
-	// Start server
-	logger.Sugar().With("port", defaultPort).Info("listening http")
-	logger.Sugar().With("port", grpcPort).Info("listening grpc")
-	return server.ListenAndServe()
+	// Start servers
+	g.Go(func() error {
+		logger.Sugar().With("port", grpcPort).Info("listening grpc")
+		return grpcServer.Serve(lis)
+	})
+	g.Go(func() error {
+		logger.Sugar().With("port", defaultPort).Info("listening http")
+		return server.ListenAndServe()
+	})
+	for _, w := range workers {
+		w := w
+		g.Go(func() error {
+			return w.Run(ctx)
+		})
+	}
+	return g.Wait()
 }
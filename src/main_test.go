@@ -1,17 +1,28 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/beldin0/users/src/migrations"
+	pb "github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userservice"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"github.com/jmoiron/sqlx"
 	"github.com/kelseyhightower/envconfig"
 	_ "github.com/lib/pq"
@@ -20,10 +31,88 @@ import (
 	"github.com/phayes/freeport"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"gotest.tools/assert"
 )
 
 var db *sqlx.DB
+var store userservice.Store
+var published = &recorder{}
+
+// recorder is a Publisher keeping the events published by the relay
+type recorder struct {
+	mu     sync.Mutex
+	events []*pb.UserEvent
+}
+
+func (r *recorder) Publish(ctx context.Context, e *pb.UserEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+// of returns the types of the events published for the user
+func (r *recorder) of(userID int32) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	types := []string{}
+	for _, e := range r.events {
+		if e.UserId == userID {
+			types = append(types, userservice.EventType(e))
+		}
+	}
+	return types
+}
+
+// failingExport is a UserServiceServer whose exports fail with err after sending their headers
+type failingExport struct {
+	pb.UnimplementedUserServiceServer
+	err error
+}
+
+func (s *failingExport) Export(req *pb.ExportRequest, stream pb.UserService_ExportServer) error {
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	return s.err
+}
+
+// searchResponse is the body returned by the search endpoint
+type searchResponse struct {
+	Users         []interface{} `json:"users"`
+	NextPageToken string        `json:"nextPageToken"`
+	TotalSize     int           `json:"totalSize"`
+}
+
+// addUser adds the user given as JSON through the gateway, returning the user as stored
+func addUser(t *testing.T, userJSON string) map[string]interface{} {
+	resp, err := http.Post("http://localhost:8080/users", "application/json", strings.NewReader(userJSON))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	added := map[string]interface{}{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&added))
+	return added
+}
+
+// searchNicknames searches through the gateway with the query string, returning the nicknames of the users found
+func searchNicknames(t *testing.T, query string) []string {
+	resp, err := http.Get("http://localhost:8080/users?" + query)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	jBody := struct{ Users []struct{ Nickname string } }{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
+	nicknames := []string{}
+	for _, u := range jBody.Users {
+		nicknames = append(nicknames, u.Nickname)
+	}
+	return nicknames
+}
 
 func TestMain(m *testing.M) {
 	shutdown, err := setup()
@@ -31,13 +120,15 @@ func TestMain(m *testing.M) {
 		log.Fatal(err)
 	}
 	defer shutdown()
-	if db == nil {
+	if store == nil {
 		log.Fatal("failed to connect")
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	g, ctx := errgroup.WithContext(ctx)
 	g.Go(func() error {
-		return run(ctx, db)
+		return run(ctx, store,
+			userservice.NewRelay(store, published, 50*time.Millisecond),
+			userservice.NewDispatcher(store, 20*time.Millisecond, 10*time.Millisecond, 2))
 	})
 	log.Println("Waiting for HTTP server to be ready")
 	expiry := time.Now().Add(2 * time.Second)
@@ -57,9 +148,16 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
+// setup prepares the Store selected by DB_DRIVER, starting a Postgres container if required
 func setup() (teardown func(), err error) {
 	c := config{}
 	envconfig.Process("", &c)
+	if c.Driver != "postgres" {
+		log.Printf("Using %s store", c.Driver)
+		c.SQLitePath = ":memory:"
+		store, err = newStore(context.Background(), c)
+		return func() {}, err
+	}
 	c.Host = "localhost"
 	c.Password = "testPassword"
 	c.Port, err = freeport.GetFreePort()
@@ -97,7 +195,13 @@ func setup() (teardown func(), err error) {
 		}
 		pool.Purge(res)
 	}
-	waitForDb(c)
+	if err := waitForDb(c); err != nil {
+		return purgeFunc, err
+	}
+	if _, err := migrations.New(db).Up(context.Background()); err != nil {
+		return purgeFunc, err
+	}
+	store = userservice.NewPostgresStore(db)
 	return purgeFunc, nil
 }
 
@@ -114,6 +218,17 @@ func waitForDb(c config) (err error) {
 	return nil
 }
 
+// withoutPassword returns a copy of the user as it is expected to be returned by the service
+func withoutPassword(user map[string]interface{}) map[string]interface{} {
+	expected := map[string]interface{}{}
+	for k, v := range user {
+		if k != "password" {
+			expected[k] = v
+		}
+	}
+	return expected
+}
+
 func TestDB(t *testing.T) {
 	// Set up a test user
 	user := map[string]interface{}{
@@ -122,11 +237,12 @@ func TestDB(t *testing.T) {
 		"nickname":  "alan112",
 		"password":  "pass",
 		"email":     "alan112@faceit.com",
-		"country":   "UK",
+		"country":   "FR",
 	}
 	userJSON, err := json.Marshal(user)
 	require.NoError(t, err)
 	var id float64
+	var etag string
 
 	t.Run("Add user", func(t *testing.T) {
 		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/users", bytes.NewReader(userJSON))
@@ -141,6 +257,22 @@ func TestDB(t *testing.T) {
 		require.Equal(t, true, ok)
 		assert.Equal(t, true, newID != 0) // assert that the returned user has an ID
 		id = newID
+		etag, ok = jBody["etag"].(string)
+		require.Equal(t, true, ok)
+		require.Equal(t, strconv.Quote(etag), resp.Header.Get("ETag"))
+	})
+	if t.Failed() {
+		return
+	}
+	t.Run("Add user - duplicate", func(t *testing.T) {
+		resp, err := http.Post("http://localhost:8080/users", "application/json", bytes.NewReader(userJSON))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusConflict, resp.StatusCode)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Contains(t, string(body), `"reason":"DUPLICATE_USER"`)
+		require.NotContains(t, string(body), "constraint") // assert that database errors are not leaked
 	})
 	if t.Failed() {
 		return
@@ -156,9 +288,11 @@ func TestDB(t *testing.T) {
 		jBody := map[string]interface{}{}
 		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
 		require.Equal(t, http.StatusOK, resp.StatusCode)
-		for k, v := range user {
+		for k, v := range withoutPassword(user) {
 			assert.Equal(t, v, jBody[k]) // assert that the values returned match the values that were added
 		}
+		_, ok := jBody["password"]
+		assert.Equal(t, false, ok) // assert that the password is never returned
 	})
 	if t.Failed() {
 		return
@@ -170,13 +304,98 @@ func TestDB(t *testing.T) {
 		require.NoError(t, err)
 		req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:8080/users/%v", id), bytes.NewReader(userJSON))
 		require.NoError(t, err)
+		req.Header.Set("If-Match", strconv.Quote(etag))
 		resp, err := http.DefaultClient.Do(req)
 		require.NoError(t, err)
 		require.Equal(t, http.StatusOK, resp.StatusCode)
 		defer resp.Body.Close()
 		jBody := map[string]interface{}{}
 		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
-		require.Equal(t, user, jBody) // assert that the values returned are the updated values
+		newEtag := jBody["etag"]
+		require.NotEqual(t, etag, newEtag) // assert that the etag changes with the user
+		etag = newEtag.(string)
+		delete(jBody, "etag")
+		require.Equal(t, withoutPassword(user), jBody) // assert that the values returned are the updated values
+	})
+	if t.Failed() {
+		return
+	}
+
+	t.Run("Modify user - stale etag", func(t *testing.T) {
+		for _, body := range []map[string]interface{}{{"etag": "1"}, {}} {
+			modified := withoutPassword(user)
+			for k, v := range body {
+				modified[k] = v
+			}
+			userJSON, err := json.Marshal(modified)
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:8080/users/%v", id), bytes.NewReader(userJSON))
+			require.NoError(t, err)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			if len(body) == 0 {
+				require.Equal(t, http.StatusBadRequest, resp.StatusCode) // assert that the etag is required
+				continue
+			}
+			require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+			respBody, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(respBody), `"reason":"ETAG_MISMATCH"`)
+		}
+	})
+	if t.Failed() {
+		return
+	}
+
+	t.Run("Update user - single field", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPatch, fmt.Sprintf("http://localhost:8080/users/%v", id), bytes.NewReader([]byte(`{"country": "gb"}`)))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		defer resp.Body.Close()
+		jBody := map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
+		user["country"] = "GB"
+		etag = jBody["etag"].(string)
+		delete(jBody, "etag")
+		require.Equal(t, withoutPassword(user), jBody) // assert that only the country was changed
+	})
+	if t.Failed() {
+		return
+	}
+
+	t.Run("Update user - unknown field", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPatch, fmt.Sprintf("http://localhost:8080/users/%v", id), bytes.NewReader([]byte(`{"id": 1}`)))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Contains(t, string(body), `"reason":"INVALID_UPDATE_MASK"`)
+	})
+	if t.Failed() {
+		return
+	}
+
+	t.Run("Verify password", func(t *testing.T) {
+		for _, login := range []string{`"email": "ALAN112@faceit.com"`, `"nickname": "Alan112"`} {
+			body := fmt.Sprintf(`{%s, "password": "pass"}`, login)
+			resp, err := http.Post("http://localhost:8080/users:verifyPassword", "application/json", bytes.NewReader([]byte(body)))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			jBody := map[string]interface{}{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
+			resp.Body.Close()
+			require.Equal(t, id, jBody["id"])
+		}
+		resp, err := http.Post("http://localhost:8080/users:verifyPassword", "application/json", bytes.NewReader([]byte(`{"email": "alan112@faceit.com", "password": "wrong"}`)))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
 	})
 	if t.Failed() {
 		return
@@ -189,9 +408,9 @@ func TestDB(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, http.StatusOK, resp.StatusCode)
 		defer resp.Body.Close()
-		jBody := map[string][]interface{}{}
+		jBody := searchResponse{}
 		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
-		require.Equal(t, 0, len(jBody["users"])) // assert that no results were returned
+		require.Equal(t, 0, len(jBody.Users)) // assert that no results were returned
 	})
 	if t.Failed() {
 		return
@@ -206,10 +425,27 @@ func TestDB(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, http.StatusOK, resp.StatusCode)
 		defer resp.Body.Close()
-		jBody := map[string][]interface{}{}
+		jBody := searchResponse{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
+		require.Equal(t, 1, len(jBody.Users)) // assert that one result was returned
+		expected := withoutPassword(user)
+		expected["etag"] = etag
+		require.Equal(t, expected, jBody.Users[0])
+	})
+	if t.Failed() {
+		return
+	}
+
+	t.Run("Search for user - special characters", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/users?nickname=o'brien%25", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		defer resp.Body.Close()
+		jBody := searchResponse{}
 		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
-		require.Equal(t, 1, len(jBody["users"])) // assert that one result was returned
-		require.Equal(t, user, jBody["users"][0])
+		require.Equal(t, 0, len(jBody.Users)) // assert that quotes and wildcards are matched literally
 	})
 	if t.Failed() {
 		return
@@ -218,6 +454,7 @@ func TestDB(t *testing.T) {
 	t.Run("Delete user", func(t *testing.T) {
 		req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://localhost:8080/users/%v", id), nil)
 		require.NoError(t, err)
+		req.Header.Set("If-Match", strconv.Quote(etag))
 		resp, err := http.DefaultClient.Do(req)
 		require.NoError(t, err)
 		require.Equal(t, http.StatusOK, resp.StatusCode)
@@ -231,10 +468,754 @@ func TestDB(t *testing.T) {
 		require.NoError(t, err)
 		resp, err := http.DefaultClient.Do(req)
 		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Modify and delete user - should not exist", func(t *testing.T) {
+		userJSON, err := json.Marshal(user)
+		require.NoError(t, err)
+		for _, method := range []string{http.MethodPut, http.MethodDelete} {
+			req, err := http.NewRequest(method, fmt.Sprintf("http://localhost:8080/users/%v", id), bytes.NewReader(userJSON))
+			require.NoError(t, err)
+			req.Header.Set("If-Match", strconv.Quote(etag))
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusNotFound, resp.StatusCode, method)
+		}
+	})
+}
+
+func TestSoftDelete(t *testing.T) {
+	userJSON := []byte(`{"firstName": "Ada", "nickname": "ada1815", "password": "pass", "email": "ada1815@faceit.com", "country": "UK"}`)
+	add := func(t *testing.T) map[string]interface{} {
+		resp, err := http.Post("http://localhost:8080/users", "application/json", bytes.NewReader(userJSON))
+		require.NoError(t, err)
+		defer resp.Body.Close()
 		require.Equal(t, http.StatusOK, resp.StatusCode)
+		jBody := map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
+		return jBody
+	}
+	do := func(t *testing.T, method, url string) (*http.Response, map[string]interface{}) {
+		req, err := http.NewRequest(method, url, nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
 		defer resp.Body.Close()
 		jBody := map[string]interface{}{}
+		json.NewDecoder(resp.Body).Decode(&jBody)
+		return resp, jBody
+	}
+
+	added := add(t)
+	resp, _ := do(t, http.MethodDelete, fmt.Sprintf("http://localhost:8080/users/%v?etag=%v", added["id"], added["etag"]))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// assert that deleted users are only found when asked for
+	_, jBody := do(t, http.MethodGet, "http://localhost:8080/users?nickname=ada1815")
+	require.Equal(t, nil, jBody["users"])
+	_, jBody = do(t, http.MethodGet, "http://localhost:8080/users?nickname=ada1815&showDeleted=true")
+	users := jBody["users"].([]interface{})
+	require.Equal(t, 1, len(users))
+	require.NotEqual(t, nil, users[0].(map[string]interface{})["deleteTime"])
+
+	resp, restored := do(t, http.MethodPost, fmt.Sprintf("http://localhost:8080/users/%v:restore", added["id"]))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, added["id"], restored["id"])
+	require.Equal(t, nil, restored["deleteTime"])
+	resp, _ = do(t, http.MethodPost, fmt.Sprintf("http://localhost:8080/users/%v:restore", added["id"]))
+	require.Equal(t, http.StatusNotFound, resp.StatusCode) // assert that only deleted users can be restored
+
+	// assert that the email and nickname of a deleted user can be reused, which prevents restoring it
+	resp, _ = do(t, http.MethodDelete, fmt.Sprintf("http://localhost:8080/users/%v?etag=%v", added["id"], restored["etag"]))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	readded := add(t)
+	resp, _ = do(t, http.MethodPost, fmt.Sprintf("http://localhost:8080/users/%v:restore", added["id"]))
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+	resp, _ = do(t, http.MethodDelete, fmt.Sprintf("http://localhost:8080/users/%v?etag=%v", readded["id"], readded["etag"]))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err := http.Post("http://localhost:8080/users:purge", "application/json", bytes.NewReader([]byte(`{"retentionDays": 0}`)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, err = http.Post("http://localhost:8080/users:purge", "application/json", bytes.NewReader([]byte(`{"retentionDays": 30}`)))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	jBody = map[string]interface{}{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
+	require.Equal(t, nil, jBody["purged"]) // assert that recently deleted users are kept
+}
+
+func TestAuditLog(t *testing.T) {
+	do := func(t *testing.T, method, url string, body []byte) map[string]interface{} {
+		req, err := http.NewRequest(method, url, bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("X-Actor", "admin@faceit.com")
+		req.Header.Set("X-Request-Id", "req-1")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		jBody := map[string]interface{}{}
 		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
-		require.Equal(t, 0, len(jBody)) // assert that no results were returned
+		return jBody
+	}
+
+	added := do(t, http.MethodPost, "http://localhost:8080/users",
+		[]byte(`{"firstName": "Alan", "nickname": "turing1912", "password": "pass", "email": "turing1912@faceit.com", "country": "UK"}`))
+	updated := do(t, http.MethodPatch, fmt.Sprintf("http://localhost:8080/users/%v", added["id"]),
+		[]byte(fmt.Sprintf(`{"lastName": "Turing", "password": "secret", "etag": "%v"}`, added["etag"])))
+	do(t, http.MethodDelete, fmt.Sprintf("http://localhost:8080/users/%v?etag=%v", added["id"], updated["etag"]), nil)
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:8080/users/%v/audit", added["id"]))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, false, strings.Contains(string(body), `"pass"`)) // assert that passwords are never recorded
+	assert.Equal(t, false, strings.Contains(string(body), `"secret"`))
+
+	jBody := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(body, &jBody))
+	events := jBody["events"].([]interface{})
+	require.Equal(t, 3, len(events))
+	for i, op := range []string{"CREATE", "UPDATE", "DELETE"} {
+		event := events[i].(map[string]interface{})
+		assert.Equal(t, op, event["operation"])
+		assert.Equal(t, "admin@faceit.com", event["actor"])
+		assert.Equal(t, "req-1", event["requestId"])
+	}
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"field": "lastName", "after": "Turing"},
+		map[string]interface{}{"field": "password", "before": "[REDACTED]", "after": "[REDACTED]"},
+	}, events[1].(map[string]interface{})["changes"])
+}
+
+func TestEvents(t *testing.T) {
+	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", grpcPort), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewUserServiceClient(conn)
+	ctx := context.Background()
+
+	added, err := client.Add(ctx, &pb.User{Nickname: "hedy1914", Password: "pass", Email: "hedy1914@faceit.com", Country: "AT"})
+	require.NoError(t, err)
+	added.FirstName = "Hedy"
+	modified, err := client.Modify(ctx, added)
+	require.NoError(t, err)
+	_, err = client.Delete(ctx, &pb.DeleteRequest{Id: added.Id, Etag: modified.Etag})
+	require.NoError(t, err)
+
+	// assert that the relay publishes the user's events in order
+	expected := []string{userservice.EventUserCreated, userservice.EventUserUpdated, userservice.EventUserDeleted}
+	expiry := time.Now().Add(2 * time.Second)
+	for len(published.of(added.Id)) < len(expected) && time.Now().Before(expiry) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Equal(t, expected, published.of(added.Id))
+}
+
+func TestWatch(t *testing.T) {
+	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", grpcPort), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewUserServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &pb.WatchRequest{})
+	require.NoError(t, err)
+	_, err = stream.Header() // assert that the stream is established before any events
+	require.NoError(t, err)
+	added, err := client.Add(ctx, &pb.User{Nickname: "katherine1918", Password: "pass", Email: "katherine1918@faceit.com", Country: "US"})
+	require.NoError(t, err)
+	added.LastName = "Johnson"
+	_, err = client.Modify(ctx, added)
+	require.NoError(t, err)
+
+	created, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, added.Id, created.GetCreated().GetUser().GetId())
+	updated, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, []string{"lastName"}, updated.GetUpdated().GetChangedFields())
+
+	// assert that HTTP watchers resume after the given event
+	resp, err := http.Get("http://localhost:8080/users:watch?resumeToken=" + created.ResumeToken)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	line, err := bufio.NewReader(resp.Body).ReadBytes('\n')
+	require.NoError(t, err)
+	jBody := map[string]map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(line, &jBody))
+	require.Equal(t, updated.ResumeToken, jBody["result"]["resumeToken"])
+
+	req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/users:watch", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Last-Event-ID", created.ResumeToken)
+	sse, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer sse.Body.Close()
+	require.Equal(t, "text/event-stream", sse.Header.Get("Content-Type"))
+	line, err = bufio.NewReader(sse.Body).ReadBytes('\n')
+	require.NoError(t, err)
+	require.Equal(t, "id: "+updated.ResumeToken+"\n", string(line))
+
+	resp, err = http.Get("http://localhost:8080/users:watch?resumeToken=invalid")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestWebhooks(t *testing.T) {
+	var mu sync.Mutex
+	failing := true
+	received := []string{}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := ioutil.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get("X-Webhook-Timestamp"), 10, 64)
+		if r.Header.Get("X-Webhook-Signature") != "sha256="+userservice.Sign("s3cret", timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		received = append(received, r.Header.Get("X-Webhook-Event"))
+	}))
+	defer receiver.Close()
+	do := func(t *testing.T, method, url string, body string) (*http.Response, map[string]interface{}) {
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		jBody := map[string]interface{}{}
+		json.NewDecoder(resp.Body).Decode(&jBody)
+		return resp, jBody
+	}
+	eventually := func(t *testing.T, condition func() bool) {
+		expiry := time.Now().Add(2 * time.Second)
+		for !condition() && time.Now().Before(expiry) {
+			time.Sleep(20 * time.Millisecond)
+		}
+		require.True(t, condition())
+	}
+
+	resp, _ := do(t, http.MethodPost, "http://localhost:8080/webhooks", `{"url": "ftp://example.com"}`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, webhook := do(t, http.MethodPost, "http://localhost:8080/webhooks",
+		fmt.Sprintf(`{"url": "%s", "events": ["UserCreated"], "secret": "s3cret"}`, receiver.URL))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	_, listed := do(t, http.MethodGet, "http://localhost:8080/webhooks", "")
+	hooks := listed["webhooks"].([]interface{})
+	require.Equal(t, 1, len(hooks))
+	require.Equal(t, nil, hooks[0].(map[string]interface{})["secret"]) // assert that secrets are not returned
+
+	resp, added := do(t, http.MethodPost, "http://localhost:8080/users",
+		`{"nickname": "margaret1936", "password": "pass", "email": "margaret1936@faceit.com", "country": "US"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = do(t, http.MethodPatch, fmt.Sprintf("http://localhost:8080/users/%v", added["id"]), `{"lastName": "Hamilton"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// assert that the delivery becomes a dead letter once every attempt has failed
+	var deadLetters []interface{}
+	eventually(t, func() bool {
+		_, jBody := do(t, http.MethodGet, "http://localhost:8080/deadLetters?webhookId="+fmt.Sprint(webhook["id"]), "")
+		deadLetters, _ = jBody["deliveries"].([]interface{})
+		return len(deadLetters) == 1
 	})
+	deadLetter := deadLetters[0].(map[string]interface{})
+	assert.Equal(t, "UserCreated", deadLetter["eventType"])
+	assert.Equal(t, float64(2), deadLetter["attempts"])
+	assert.Equal(t, "webhook responded with 500 Internal Server Error", deadLetter["lastError"])
+
+	// assert that a replayed dead letter is delivered, and that only subscribed events are delivered
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	resp, _ = do(t, http.MethodPost, fmt.Sprintf("http://localhost:8080/deadLetters/%v:replay", deadLetter["id"]), "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 1
+	})
+	assert.Equal(t, "UserCreated", received[0])
+	resp, _ = do(t, http.MethodPost, fmt.Sprintf("http://localhost:8080/deadLetters/%v:replay", deadLetter["id"]), "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = do(t, http.MethodDelete, fmt.Sprintf("http://localhost:8080/webhooks/%v", webhook["id"]), "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = do(t, http.MethodDelete, fmt.Sprintf("http://localhost:8080/webhooks/%v", webhook["id"]), "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestImport(t *testing.T) {
+	upload := func(t *testing.T, url, contentType, body string) map[string]interface{} {
+		resp, err := http.Post(url, contentType, strings.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		jBody := map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
+		return jBody
+	}
+	found := func(t *testing.T, nickname string) bool {
+		resp, err := http.Get("http://localhost:8080/users?nickname=" + nickname)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		jBody := map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
+		return jBody["users"] != nil
+	}
+
+	csv := "first_name,lastName,nickname,password,email,country\n" +
+		"Barbara,Liskov,barbara1939,pass,barbara1939@faceit.com,US\n" +
+		"Frances,Allen,frances1932,pass,frances1932@faceit.com,US\n" +
+		"Copy,Cat,barbara1939,pass,copycat@faceit.com,US\n" +
+		"No,Email,noemail,pass,,US\n"
+	summary := upload(t, "http://localhost:8080/users:import", "text/csv", csv)
+	assert.Equal(t, float64(2), summary["imported"])
+	assert.Equal(t, float64(2), summary["failed"])
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"line": float64(4), "reason": "DUPLICATE_USER", "message": "email or nickname already in use"},
+		map[string]interface{}{"line": float64(5), "reason": "INVALID_USER", "message": "email is required"},
+	}, summary["errors"])
+	assert.Equal(t, true, found(t, "frances1932"))
+
+	// assert that imported users are audited and their events published, as when added one at a time
+	resp, err := http.Get("http://localhost:8080/users?nickname=frances1932")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	found1932 := searchResponse{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&found1932))
+	id := int32(found1932.Users[0].(map[string]interface{})["id"].(float64))
+	audit, err := store.AuditEvents(id, time.Time{}, time.Time{}, userservice.Page{})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(audit.Events))
+	assert.Equal(t, "CREATE", audit.Events[0].Operation)
+	expiry := time.Now().Add(2 * time.Second)
+	for len(published.of(id)) == 0 && time.Now().Before(expiry) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Equal(t, []string{userservice.EventUserCreated}, published.of(id))
+
+	// assert that a dry run reports the same errors without storing any users
+	jsonl := `{"nickname": "radia1951", "password": "pass", "email": "radia1951@faceit.com", "country": "US"}` + "\n\n" +
+		`{"nickname": "frances1932", "password": "pass", "email": "frances@faceit.com", "country": "US"}` + "\n" +
+		"not json\n"
+	summary = upload(t, "http://localhost:8080/users:import?dryRun=true", "application/x-ndjson", jsonl)
+	assert.Equal(t, true, summary["dryRun"])
+	assert.Equal(t, float64(1), summary["imported"])
+	errs := summary["errors"].([]interface{})
+	require.Equal(t, 2, len(errs))
+	assert.Equal(t, float64(3), errs[0].(map[string]interface{})["line"])
+	assert.Equal(t, "DUPLICATE_USER", errs[0].(map[string]interface{})["reason"])
+	assert.Equal(t, float64(4), errs[1].(map[string]interface{})["line"])
+	assert.Equal(t, "INVALID_ROW", errs[1].(map[string]interface{})["reason"])
+	assert.Equal(t, false, found(t, "radia1951"))
+
+	resp, err = http.Post("http://localhost:8080/users:import", "text/csv", strings.NewReader("nickname,age\n"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// assert that gRPC clients can stream users, numbered from 1
+	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", grpcPort), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	stream, err := pb.NewUserServiceClient(conn).Import(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.User{Nickname: "radia1951", Password: "pass", Email: "radia1951@faceit.com"}))
+	require.NoError(t, stream.Send(&pb.User{Nickname: "radia", Email: "radia@faceit.com"}))
+	result, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), result.Imported)
+	require.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, int32(2), result.Errors[0].Line)
+	assert.Equal(t, "MISSING_PASSWORD", result.Errors[0].Reason)
+	assert.Equal(t, true, found(t, "radia1951"))
+}
+
+func TestExport(t *testing.T) {
+	for _, nick := range []string{"edsger1930", "tony1934", "niklaus1934"} {
+		addUser(t, fmt.Sprintf(`{"firstName": "Export", "nickname": "%s", "password": "pass", "email": "%s@faceit.com", "country": "NR"}`, nick, nick))
+	}
+	get := func(t *testing.T, url string) (*http.Response, string) {
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(body)
+	}
+
+	resp, body := get(t, "http://localhost:8080/users:export?format=csv&country=nauru&readMask=nickname,email")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/csv", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "nickname,email\n"+
+		"edsger1930,edsger1930@faceit.com\n"+
+		"tony1934,tony1934@faceit.com\n"+
+		"niklaus1934,niklaus1934@faceit.com\n", body)
+
+	resp, body = get(t, "http://localhost:8080/users:export?country=nauru&readMask=nickname")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `{"nickname":"edsger1930"}`+"\n"+`{"nickname":"tony1934"}`+"\n"+`{"nickname":"niklaus1934"}`+"\n", body)
+
+	resp, _ = get(t, "http://localhost:8080/users:export?readMask=password")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// assert that errors before the first user are returned with their status rather than aborting the response
+	mux := runtime.NewServeMux()
+	mux.Handle(http.MethodGet, patternExport, handleExport(mux, &failingExport{
+		err: status.Error(codes.InvalidArgument, "invalid page token"),
+	}))
+	failing := httptest.NewServer(mux)
+	defer failing.Close()
+	resp, body = get(t, failing.URL+"/users:export?format=csv")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Assert(t, strings.Contains(body, "invalid page token"))
+
+	// assert that gRPC clients receive every field by default
+	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", grpcPort), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	stream, err := pb.NewUserServiceClient(conn).Export(context.Background(), &pb.ExportRequest{Country: "NRU"})
+	require.NoError(t, err)
+	nicknames := []string{}
+	for {
+		u, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		assert.Equal(t, "Export", u.FirstName)
+		assert.Equal(t, true, u.Id != 0 && u.Etag != "")
+		nicknames = append(nicknames, u.Nickname)
+	}
+	require.Equal(t, []string{"edsger1930", "tony1934", "niklaus1934"}, nicknames)
+}
+
+func TestValidation(t *testing.T) {
+	userJSON := `{"firstName": "` + strings.Repeat("a", 51) + `", "nickname": "bad nick", "password": "pass", "email": "not-an-email", "country": "BRITAIN"}`
+	resp, err := http.Post("http://localhost:8080/users", "application/json", strings.NewReader(userJSON))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	jBody := map[string]interface{}{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
+	assert.Equal(t, "firstName must be at most 50 characters; "+
+		"nickname may only contain letters, digits, underscores, dots and hyphens; "+
+		"email must be a valid email address; "+
+		"country must be an ISO 3166 country code or name", jBody["message"])
+	details := jBody["details"].([]interface{})
+	require.Equal(t, 2, len(details))
+	assert.Equal(t, "INVALID_USER", details[0].(map[string]interface{})["reason"])
+	violations := details[1].(map[string]interface{})["field_violations"].([]interface{})
+	fields := []string{}
+	for _, v := range violations {
+		fields = append(fields, v.(map[string]interface{})["field"].(string))
+	}
+	require.Equal(t, []string{"firstName", "nickname", "email", "country"}, fields)
+
+	// assert that partial updates only validate the updated fields
+	added, err := http.Post("http://localhost:8080/users", "application/json",
+		strings.NewReader(`{"nickname": "barbara1934", "password": "pass", "email": "barbara1934@faceit.com"}`))
+	require.NoError(t, err)
+	defer added.Body.Close()
+	require.Equal(t, http.StatusOK, added.StatusCode)
+	user := map[string]interface{}{}
+	require.NoError(t, json.NewDecoder(added.Body).Decode(&user))
+	for body, code := range map[string]int{`{"country": "GBR"}`: http.StatusOK, `{"email": "barbara"}`: http.StatusBadRequest} {
+		req, err := http.NewRequest(http.MethodPatch, fmt.Sprintf("http://localhost:8080/users/%v", user["id"]), strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, code, resp.StatusCode)
+	}
+}
+
+func TestCountries(t *testing.T) {
+	resp, err := http.Get("http://localhost:8080/countries?region=oceania")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	jBody := map[string][]map[string]interface{}{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
+	countries := jBody["countries"]
+	require.Equal(t, 29, len(countries))
+	require.Equal(t, map[string]interface{}{"alpha2": "AS", "alpha3": "ASM", "name": "American Samoa", "region": "Oceania"}, countries[0])
+
+	unknown, err := http.Get("http://localhost:8080/countries?region=atlantis")
+	require.NoError(t, err)
+	unknown.Body.Close()
+	require.Equal(t, http.StatusBadRequest, unknown.StatusCode)
+
+	// assert that codes, aliases and names of a country are all stored as its alpha-2 code
+	for i, country := range []string{"UK", "gbr", "united kingdom"} {
+		nick := fmt.Sprintf("countries%d", i)
+		added := addUser(t, fmt.Sprintf(`{"nickname": "%s", "password": "pass", "email": "%s@faceit.com", "country": "%s"}`, nick, nick, country))
+		assert.Equal(t, "GB", added["country"])
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", grpcPort), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewUserServiceClient(conn)
+	ctx := context.Background()
+
+	added, err := client.Add(ctx, &pb.User{
+		FirstName: "Grace",
+		LastName:  "Hopper",
+		Nickname:  "grace1906",
+		Password:  "pass",
+		Email:     "grace1906@faceit.com",
+		Country:   "US",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, true, added.Id != 0) // assert that the returned user has an ID
+
+	got, err := client.Get(ctx, &pb.UserId{Id: added.Id})
+	require.NoError(t, err)
+	require.Equal(t, "grace1906", got.Nickname)
+
+	_, err = client.Delete(ctx, &pb.DeleteRequest{Id: added.Id, Etag: got.Etag})
+	require.NoError(t, err)
+}
+
+func TestBatchGet(t *testing.T) {
+	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", grpcPort), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewUserServiceClient(conn)
+	ctx := context.Background()
+	ids := []int32{}
+	for _, nick := range []string{"batch1", "batch2", "batch3"} {
+		added, err := client.Add(ctx, &pb.User{Nickname: nick, Password: "pass", Email: nick + "@faceit.com"})
+		require.NoError(t, err)
+		ids = append(ids, added.Id)
+	}
+	got, err := client.Get(ctx, &pb.UserId{Id: ids[2]})
+	require.NoError(t, err)
+	_, err = client.Delete(ctx, &pb.DeleteRequest{Id: ids[2], Etag: got.Etag})
+	require.NoError(t, err)
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:8080/users:batchGet?ids=%d&ids=999999&ids=%d&ids=%d", ids[1], ids[0], ids[2]))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	jBody := struct {
+		Results []struct {
+			ID      int32
+			User    *struct{ Nickname string }
+			Missing bool
+		}
+	}{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
+	require.Equal(t, 4, len(jBody.Results))
+	// assert that users are returned in the requested order, and unknown and deleted users are missing
+	for i, expected := range []struct {
+		id       int32
+		nickname string
+	}{{ids[1], "batch2"}, {999999, ""}, {ids[0], "batch1"}, {ids[2], ""}} {
+		result := jBody.Results[i]
+		assert.Equal(t, expected.id, result.ID)
+		assert.Equal(t, expected.nickname == "", result.Missing)
+		if expected.nickname != "" {
+			require.NotNil(t, result.User)
+			assert.Equal(t, expected.nickname, result.User.Nickname)
+		} else {
+			assert.Assert(t, result.User == nil)
+		}
+	}
+
+	_, err = client.BatchGet(ctx, &pb.BatchGetRequest{Ids: make([]int32, 1001)})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestSearchPagination(t *testing.T) {
+	etags := map[interface{}]interface{}{}
+	for _, nick := range []string{"page3", "page1", "page2"} {
+		userJSON, err := json.Marshal(map[string]interface{}{
+			"firstName": "Page",
+			"lastName":  "Test",
+			"nickname":  nick,
+			"password":  "pass",
+			"email":     nick + "@faceit.com",
+			"country":   "TV",
+		})
+		require.NoError(t, err)
+		resp, err := http.Post("http://localhost:8080/users", "application/json", bytes.NewReader(userJSON))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		jBody := map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
+		resp.Body.Close()
+		etags[jBody["id"]] = jBody["etag"]
+	}
+	defer func() {
+		for id, etag := range etags {
+			req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://localhost:8080/users/%v?etag=%v", id, etag), nil)
+			if resp, err := http.DefaultClient.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}()
+
+	search := func(t *testing.T, url string) map[string]interface{} {
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		defer resp.Body.Close()
+		jBody := map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
+		return jBody
+	}
+
+	first := search(t, "http://localhost:8080/users?country=TV&pageSize=2&orderBy=nickname")
+	users := first["users"].([]interface{})
+	require.Equal(t, 2, len(users))
+	require.Equal(t, "page1", users[0].(map[string]interface{})["nickname"])
+	require.Equal(t, "page2", users[1].(map[string]interface{})["nickname"])
+	require.Equal(t, float64(3), first["totalSize"])
+	token, ok := first["nextPageToken"].(string)
+	require.Equal(t, true, ok)
+
+	second := search(t, "http://localhost:8080/users?country=TV&pageSize=2&orderBy=nickname&pageToken="+token)
+	users = second["users"].([]interface{})
+	require.Equal(t, 1, len(users))
+	require.Equal(t, "page3", users[0].(map[string]interface{})["nickname"])
+	require.Equal(t, nil, second["nextPageToken"]) // assert that the last page has no token
+
+	resp, err := http.Get("http://localhost:8080/users?orderBy=password")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestFilter(t *testing.T) {
+	before := time.Now().UTC().Add(-time.Second).Format(time.RFC3339)
+	ids := map[string]interface{}{}
+	for _, nick := range []string{"alice1", "alison1", "bob1"} {
+		email := nick + "@faceit.com"
+		if nick == "bob1" {
+			email = "bob1@acme.com"
+		}
+		added := addUser(t, fmt.Sprintf(`{"nickname": "%s", "password": "pass", "email": "%s", "country": "FJ"}`, nick, email))
+		ids[nick] = added["id"]
+		if nick == "alison1" {
+			req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://localhost:8080/users/%v?etag=%v", added["id"], added["etag"]), nil)
+			require.NoError(t, err)
+			deleted, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			deleted.Body.Close()
+			require.Equal(t, http.StatusOK, deleted.StatusCode)
+		}
+	}
+	search := func(t *testing.T, filter string, showDeleted bool) []string {
+		return searchNicknames(t, fmt.Sprintf("showDeleted=%t&filter=%s", showDeleted, url.QueryEscape(filter)))
+	}
+
+	require.Equal(t, []string{"alice1", "bob1"}, search(t, `country = "fiji" AND (nickname:"ali*" OR email:"*@acme.com")`, false))
+	require.Equal(t, []string{"alice1", "alison1"}, search(t, `country = FJ AND NOT email:"*@acme.com"`, true))
+	require.Equal(t, []string{"alison1"}, search(t, fmt.Sprintf(`country = FJ deleteTime > "%s"`, before), true))
+	require.Equal(t, []string{"bob1"}, search(t, fmt.Sprintf(`id IN (%v, %v) id > %v`, ids["alice1"], ids["bob1"], ids["alice1"]), false))
+
+	resp, err := http.Get("http://localhost:8080/users?filter=" + url.QueryEscape(`country = "GB" AND`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), `"reason":"INVALID_FILTER"`)
+	require.Contains(t, string(body), "invalid filter at position 19: expected a field but found end of filter")
+}
+
+func TestMatchModes(t *testing.T) {
+	for _, nick := range []string{"match1", "rematch1"} {
+		addUser(t, fmt.Sprintf(`{"nickname": "%s", "password": "pass", "email": "%s@faceit.com", "country": "WS"}`, nick, nick))
+	}
+
+	// assert that names are matched by containing the value, and emails and countries exactly, by default
+	assert.DeepEqual(t, []string{"match1", "rematch1"}, searchNicknames(t, "country=WS&nickname=MATCH"))
+	assert.DeepEqual(t, []string{}, searchNicknames(t, "country=W&nickname=match"))
+	assert.DeepEqual(t, []string{}, searchNicknames(t, "country=WS&email=match1@faceit"))
+
+	assert.DeepEqual(t, []string{"match1"}, searchNicknames(t, "country=WS&nickname=match&nicknameMatch=PREFIX"))
+	assert.DeepEqual(t, []string{"match1"}, searchNicknames(t, "country=WS&nickname=match1&nicknameMatch=EXACT"))
+	assert.DeepEqual(t, []string{"match1", "rematch1"}, searchNicknames(t, "country=W&countryMatch=PREFIX&nickname=match"))
+	assert.DeepEqual(t, []string{"match1"}, searchNicknames(t, "country=WS&email=match1@faceit&emailMatch=PREFIX"))
+	assert.DeepEqual(t, []string{"rematch1"}, searchNicknames(t, "country=WS&email=rematch&emailMatch=CONTAINS"))
+
+	// assert that modes MatchMode does not declare are rejected, rather than matched as CONTAINS
+	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", grpcPort), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = pb.NewUserServiceClient(conn).Search(context.Background(),
+		&pb.SearchRequest{Email: "rematch", EmailMatch: pb.MatchMode(7)})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Equal(t, "emailMatch must be one of EXACT, PREFIX or CONTAINS", status.Convert(err).Message())
+}
+
+func TestFuzzySearch(t *testing.T) {
+	for i, name := range [][]string{{"John", "Smith"}, {"Jon", "Smith"}, {"Mary", "Jones"}} {
+		nick := fmt.Sprintf("fuzzy%d", i)
+		addUser(t, fmt.Sprintf(`{"firstName": "%s", "lastName": "%s", "nickname": "%s", "password": "pass", "email": "%s@faceit.com", "country": "KI"}`,
+			name[0], name[1], nick, nick))
+	}
+	search := func(t *testing.T, url string) ([]string, []float64, string) {
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		jBody := struct {
+			Users []struct {
+				FirstName string
+				LastName  string
+			}
+			Scores        []float64
+			NextPageToken string
+		}{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jBody))
+		names := []string{}
+		for _, u := range jBody.Users {
+			names = append(names, u.FirstName+" "+u.LastName)
+		}
+		return names, jBody.Scores, jBody.NextPageToken
+	}
+
+	// assert that misspellings are tolerated
+	names, _, _ := search(t, "http://localhost:8080/users?country=KI&query=Jonh%20Smth")
+	require.Equal(t, []string{"Jon Smith", "John Smith"}, names)
+
+	// assert that users are ordered by relevance, which pages are keyed on
+	names, scores, token := search(t, "http://localhost:8080/users?country=KI&query=john%20smith&pageSize=1")
+	require.Equal(t, []string{"John Smith"}, names)
+	require.Equal(t, []float64{1}, scores)
+	names, scores, token = search(t, "http://localhost:8080/users?country=KI&query=john%20smith&pageSize=1&pageToken="+token)
+	require.Equal(t, []string{"Jon Smith"}, names)
+	require.InDelta(t, 0.615, scores[0], 0.001)
+	require.Equal(t, "", token)
+
+	// assert that fuzzy searches can be ordered by other fields, and relevance requires a query
+	names, _, _ = search(t, "http://localhost:8080/users?country=KI&query=john%20smith&orderBy=nickname")
+	require.Equal(t, []string{"John Smith", "Jon Smith"}, names)
+	resp, err := http.Get("http://localhost:8080/users?country=KI&orderBy=relevance")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
 }
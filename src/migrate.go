@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/beldin0/users/src/logging"
+	"github.com/beldin0/users/src/migrations"
+	"github.com/jmoiron/sqlx"
+)
+
+const migrateUsage = "usage: migrate [up | down [steps] | status]"
+
+// migrate runs the migrate subcommand against the database
+func migrate(ctx context.Context, db *sqlx.DB, args []string) error {
+	logger := logging.NewLogger()
+	m := migrations.New(db)
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch command {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Sugar().With("applied", applied).Info("database migrated")
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q: %s", args[1], migrateUsage)
+			}
+			steps = n
+		}
+		reverted, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		logger.Sugar().With("reverted", reverted).Info("database migrated")
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			fmt.Println(status)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q: %s", command, migrateUsage)
+	}
+	return nil
+}
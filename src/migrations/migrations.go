@@ -0,0 +1,387 @@
+package migrations
+
+// Migration is a single versioned change to the database schema
+type Migration struct {
+	Version int
+	Name    string
+	Up      Statements
+	Down    Statements
+}
+
+// Statements holds the SQL for one direction of a migration in each supported database.
+// An empty statement means there is nothing to change in that database.
+type Statements struct {
+	Postgres string
+	SQLite   string
+}
+
+// all is the ordered set of migrations, each applied at most once.
+// Released migrations must never be edited; add a new migration instead.
+var all = []Migration{
+	{
+		Version: 1,
+		Name:    "create_users",
+		Up: Statements{
+			Postgres: `CREATE TABLE IF NOT EXISTS users (
+				id SERIAL PRIMARY KEY,
+				first_name VARCHAR(50),
+				first_name_lower VARCHAR(50),
+				last_name VARCHAR(50),
+				last_name_lower VARCHAR(50),
+				nickname VARCHAR(30),
+				nickname_lower VARCHAR(30) UNIQUE,
+				password VARCHAR(32),
+				email VARCHAR(50) UNIQUE,
+				country VARCHAR(3)
+			)`,
+			SQLite: `CREATE TABLE IF NOT EXISTS users (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				first_name VARCHAR(50),
+				first_name_lower VARCHAR(50),
+				last_name VARCHAR(50),
+				last_name_lower VARCHAR(50),
+				nickname VARCHAR(30),
+				nickname_lower VARCHAR(30) UNIQUE,
+				password VARCHAR(32),
+				email VARCHAR(50) UNIQUE,
+				country VARCHAR(3)
+			)`,
+		},
+		Down: Statements{
+			Postgres: `DROP TABLE users`,
+			SQLite:   `DROP TABLE users`,
+		},
+	},
+	{
+		Version: 2,
+		Name:    "hash_passwords",
+		// SQLite does not enforce VARCHAR lengths, so only Postgres needs the wider column
+		Up: Statements{
+			Postgres: `ALTER TABLE users ALTER COLUMN password TYPE VARCHAR(128)`,
+		},
+		Down: Statements{
+			Postgres: `ALTER TABLE users ALTER COLUMN password TYPE VARCHAR(32)`,
+		},
+	},
+	{
+		Version: 3,
+		Name:    "add_user_version",
+		Up: Statements{
+			Postgres: `ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 1`,
+			SQLite:   `ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 1`,
+		},
+		// SQLite cannot drop columns, so the table is rebuilt without it
+		Down: Statements{
+			Postgres: `ALTER TABLE users DROP COLUMN version`,
+			SQLite: `CREATE TABLE users_v2 (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				first_name VARCHAR(50),
+				first_name_lower VARCHAR(50),
+				last_name VARCHAR(50),
+				last_name_lower VARCHAR(50),
+				nickname VARCHAR(30),
+				nickname_lower VARCHAR(30) UNIQUE,
+				password VARCHAR(32),
+				email VARCHAR(50) UNIQUE,
+				country VARCHAR(3)
+			);
+			INSERT INTO users_v2 SELECT id, first_name, first_name_lower, last_name, last_name_lower,
+				nickname, nickname_lower, password, email, country FROM users;
+			DROP TABLE users;
+			ALTER TABLE users_v2 RENAME TO users`,
+		},
+	},
+	{
+		Version: 4,
+		Name:    "soft_delete_users",
+		// Deleted users keep their rows, so email and nickname are only unique among live users
+		Up: Statements{
+			Postgres: `ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+			ALTER TABLE users DROP CONSTRAINT users_nickname_lower_key;
+			ALTER TABLE users DROP CONSTRAINT users_email_key;
+			CREATE UNIQUE INDEX users_nickname_lower_live_key ON users (nickname_lower) WHERE deleted_at IS NULL;
+			CREATE UNIQUE INDEX users_email_live_key ON users (email) WHERE deleted_at IS NULL;
+			CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL`,
+			// SQLite cannot drop the unique constraints, so the table is rebuilt without them
+			SQLite: `CREATE TABLE users_v4 (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				first_name VARCHAR(50),
+				first_name_lower VARCHAR(50),
+				last_name VARCHAR(50),
+				last_name_lower VARCHAR(50),
+				nickname VARCHAR(30),
+				nickname_lower VARCHAR(30),
+				password VARCHAR(32),
+				email VARCHAR(50),
+				country VARCHAR(3),
+				version BIGINT NOT NULL DEFAULT 1,
+				deleted_at TIMESTAMP
+			);
+			INSERT INTO users_v4 (id, first_name, first_name_lower, last_name, last_name_lower, nickname, nickname_lower, password, email, country, version)
+				SELECT id, first_name, first_name_lower, last_name, last_name_lower, nickname, nickname_lower, password, email, country, version FROM users;
+			DROP TABLE users;
+			ALTER TABLE users_v4 RENAME TO users;
+			CREATE UNIQUE INDEX users_nickname_lower_live_key ON users (nickname_lower) WHERE deleted_at IS NULL;
+			CREATE UNIQUE INDEX users_email_live_key ON users (email) WHERE deleted_at IS NULL;
+			CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL`,
+		},
+		// Deleted users are purged so that the unique constraints can be restored
+		Down: Statements{
+			Postgres: `DELETE FROM users WHERE deleted_at IS NOT NULL;
+			DROP INDEX users_deleted_at_idx;
+			DROP INDEX users_email_live_key;
+			DROP INDEX users_nickname_lower_live_key;
+			ALTER TABLE users
+				ADD CONSTRAINT users_nickname_lower_key UNIQUE (nickname_lower),
+				ADD CONSTRAINT users_email_key UNIQUE (email),
+				DROP COLUMN deleted_at`,
+			SQLite: `DELETE FROM users WHERE deleted_at IS NOT NULL;
+			CREATE TABLE users_v3 (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				first_name VARCHAR(50),
+				first_name_lower VARCHAR(50),
+				last_name VARCHAR(50),
+				last_name_lower VARCHAR(50),
+				nickname VARCHAR(30),
+				nickname_lower VARCHAR(30) UNIQUE,
+				password VARCHAR(32),
+				email VARCHAR(50) UNIQUE,
+				country VARCHAR(3),
+				version BIGINT NOT NULL DEFAULT 1
+			);
+			INSERT INTO users_v3 (id, first_name, first_name_lower, last_name, last_name_lower, nickname, nickname_lower, password, email, country, version)
+				SELECT id, first_name, first_name_lower, last_name, last_name_lower, nickname, nickname_lower, password, email, country, version FROM users;
+			DROP TABLE users;
+			ALTER TABLE users_v3 RENAME TO users`,
+		},
+	},
+	{
+		Version: 5,
+		Name:    "create_user_audit",
+		// user_id has no foreign key so that the events of purged users are kept
+		Up: Statements{
+			Postgres: `CREATE TABLE user_audit (
+				id BIGSERIAL PRIMARY KEY,
+				user_id INTEGER NOT NULL,
+				actor VARCHAR(100) NOT NULL,
+				operation VARCHAR(20) NOT NULL,
+				changes TEXT NOT NULL,
+				request_id VARCHAR(100) NOT NULL,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL
+			);
+			CREATE INDEX user_audit_user_id_idx ON user_audit (user_id, id)`,
+			SQLite: `CREATE TABLE user_audit (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				actor VARCHAR(100) NOT NULL,
+				operation VARCHAR(20) NOT NULL,
+				changes TEXT NOT NULL,
+				request_id VARCHAR(100) NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			);
+			CREATE INDEX user_audit_user_id_idx ON user_audit (user_id, id)`,
+		},
+		Down: Statements{
+			Postgres: `DROP TABLE user_audit`,
+			SQLite:   `DROP TABLE user_audit`,
+		},
+	},
+	{
+		Version: 6,
+		Name:    "create_outbox",
+		// events are written in the transaction changing the user, and published by the relay
+		Up: Statements{
+			Postgres: `CREATE TABLE outbox (
+				id BIGSERIAL PRIMARY KEY,
+				user_id INTEGER NOT NULL,
+				event_type VARCHAR(50) NOT NULL,
+				payload BYTEA NOT NULL,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				published_at TIMESTAMP WITH TIME ZONE
+			);
+			CREATE INDEX outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL`,
+			SQLite: `CREATE TABLE outbox (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				event_type VARCHAR(50) NOT NULL,
+				payload BLOB NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+				published_at TIMESTAMP
+			);
+			CREATE INDEX outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL`,
+		},
+		Down: Statements{
+			Postgres: `DROP TABLE outbox`,
+			SQLite:   `DROP TABLE outbox`,
+		},
+	},
+	{
+		Version: 7,
+		Name:    "create_webhooks",
+		// deliveries are deleted with their webhook by the store, as SQLite does not enforce foreign keys by default
+		Up: Statements{
+			Postgres: `CREATE TABLE webhooks (
+				id SERIAL PRIMARY KEY,
+				url VARCHAR(2048) NOT NULL,
+				events VARCHAR(200) NOT NULL,
+				secret VARCHAR(200) NOT NULL,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL
+			);
+			CREATE TABLE webhook_deliveries (
+				id BIGSERIAL PRIMARY KEY,
+				webhook_id INTEGER NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+				event_id BIGINT NOT NULL,
+				state VARCHAR(20) NOT NULL,
+				attempts INTEGER NOT NULL,
+				last_error TEXT NOT NULL,
+				next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL
+			);
+			CREATE INDEX webhook_deliveries_state_idx ON webhook_deliveries (state, next_attempt_at)`,
+			SQLite: `CREATE TABLE webhooks (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				url VARCHAR(2048) NOT NULL,
+				events VARCHAR(200) NOT NULL,
+				secret VARCHAR(200) NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			);
+			CREATE TABLE webhook_deliveries (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				webhook_id INTEGER NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+				event_id BIGINT NOT NULL,
+				state VARCHAR(20) NOT NULL,
+				attempts INTEGER NOT NULL,
+				last_error TEXT NOT NULL,
+				next_attempt_at TIMESTAMP NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			);
+			CREATE INDEX webhook_deliveries_state_idx ON webhook_deliveries (state, next_attempt_at)`,
+		},
+		Down: Statements{
+			Postgres: `DROP TABLE webhook_deliveries;
+			DROP TABLE webhooks`,
+			SQLite: `DROP TABLE webhook_deliveries;
+			DROP TABLE webhooks`,
+		},
+	},
+	{
+		Version: 8,
+		Name:    "normalize_countries",
+		// countries were stored as given, so the alpha-3 codes and aliases of a country are changed to its alpha-2 code.
+		// The original codes cannot be told apart afterwards, so reverting leaves them as they are.
+		Up: Statements{
+			Postgres: normalizeCountries,
+			SQLite:   normalizeCountries,
+		},
+		Down: Statements{
+			Postgres: `SELECT 1`,
+			SQLite:   `SELECT 1`,
+		},
+	},
+	{
+		Version: 9,
+		Name:    "index_name_trigrams",
+		// fuzzy searches match full names and nicknames with the pg_trgm % operator, which these indexes serve.
+		// SQLite compares every user instead.
+		Up: Statements{
+			Postgres: `CREATE EXTENSION IF NOT EXISTS pg_trgm;
+			CREATE INDEX users_full_name_trgm_idx ON users USING GIN ((first_name_lower || ' ' || last_name_lower) gin_trgm_ops);
+			CREATE INDEX users_nickname_trgm_idx ON users USING GIN (nickname_lower gin_trgm_ops)`,
+		},
+		Down: Statements{
+			Postgres: `DROP INDEX users_nickname_trgm_idx;
+			DROP INDEX users_full_name_trgm_idx`,
+		},
+	},
+	{
+		Version: 10,
+		Name:    "index_search_prefixes",
+		// prefix searches use LIKE 'value%', which Postgres only serves from indexes with pattern operators
+		// (unless the database uses the C collation), and SQLite from NOCASE indexes
+		Up: Statements{
+			Postgres: `CREATE INDEX users_first_name_prefix_idx ON users (first_name_lower varchar_pattern_ops);
+			CREATE INDEX users_last_name_prefix_idx ON users (last_name_lower varchar_pattern_ops);
+			CREATE INDEX users_nickname_prefix_idx ON users (nickname_lower varchar_pattern_ops);
+			CREATE INDEX users_email_prefix_idx ON users (email varchar_pattern_ops)`,
+			SQLite: `CREATE INDEX users_first_name_prefix_idx ON users (first_name_lower COLLATE NOCASE);
+			CREATE INDEX users_last_name_prefix_idx ON users (last_name_lower COLLATE NOCASE);
+			CREATE INDEX users_nickname_prefix_idx ON users (nickname_lower COLLATE NOCASE);
+			CREATE INDEX users_email_prefix_idx ON users (email COLLATE NOCASE)`,
+		},
+		Down: Statements{
+			Postgres: `DROP INDEX users_email_prefix_idx;
+			DROP INDEX users_nickname_prefix_idx;
+			DROP INDEX users_last_name_prefix_idx;
+			DROP INDEX users_first_name_prefix_idx`,
+			SQLite: `DROP INDEX users_email_prefix_idx;
+			DROP INDEX users_nickname_prefix_idx;
+			DROP INDEX users_last_name_prefix_idx;
+			DROP INDEX users_first_name_prefix_idx`,
+		},
+	},
+}
+
+// normalizeCountries changes the alpha-3 codes and aliases of each country, as listed when it was released,
+// to its alpha-2 code, and bumps the version of the changed users so that their etags change
+const normalizeCountries = `UPDATE users SET version = version + 1, country = CASE country
+	WHEN 'ABW' THEN 'AW' WHEN 'AFG' THEN 'AF' WHEN 'AGO' THEN 'AO' WHEN 'AIA' THEN 'AI' WHEN 'ALA' THEN 'AX' WHEN 'ALB' THEN 'AL'
+	WHEN 'AND' THEN 'AD' WHEN 'ARE' THEN 'AE' WHEN 'ARG' THEN 'AR' WHEN 'ARM' THEN 'AM' WHEN 'ASM' THEN 'AS' WHEN 'ATA' THEN 'AQ'
+	WHEN 'ATF' THEN 'TF' WHEN 'ATG' THEN 'AG' WHEN 'AUS' THEN 'AU' WHEN 'AUT' THEN 'AT' WHEN 'AZE' THEN 'AZ' WHEN 'BDI' THEN 'BI'
+	WHEN 'BEL' THEN 'BE' WHEN 'BEN' THEN 'BJ' WHEN 'BES' THEN 'BQ' WHEN 'BFA' THEN 'BF' WHEN 'BGD' THEN 'BD' WHEN 'BGR' THEN 'BG'
+	WHEN 'BHR' THEN 'BH' WHEN 'BHS' THEN 'BS' WHEN 'BIH' THEN 'BA' WHEN 'BLM' THEN 'BL' WHEN 'BLR' THEN 'BY' WHEN 'BLZ' THEN 'BZ'
+	WHEN 'BMU' THEN 'BM' WHEN 'BOL' THEN 'BO' WHEN 'BRA' THEN 'BR' WHEN 'BRB' THEN 'BB' WHEN 'BRN' THEN 'BN' WHEN 'BTN' THEN 'BT'
+	WHEN 'BVT' THEN 'BV' WHEN 'BWA' THEN 'BW' WHEN 'CAF' THEN 'CF' WHEN 'CAN' THEN 'CA' WHEN 'CCK' THEN 'CC' WHEN 'CHE' THEN 'CH'
+	WHEN 'CHL' THEN 'CL' WHEN 'CHN' THEN 'CN' WHEN 'CIV' THEN 'CI' WHEN 'CMR' THEN 'CM' WHEN 'COD' THEN 'CD' WHEN 'COG' THEN 'CG'
+	WHEN 'COK' THEN 'CK' WHEN 'COL' THEN 'CO' WHEN 'COM' THEN 'KM' WHEN 'CPV' THEN 'CV' WHEN 'CRI' THEN 'CR' WHEN 'CUB' THEN 'CU'
+	WHEN 'CUW' THEN 'CW' WHEN 'CXR' THEN 'CX' WHEN 'CYM' THEN 'KY' WHEN 'CYP' THEN 'CY' WHEN 'CZE' THEN 'CZ' WHEN 'DEU' THEN 'DE'
+	WHEN 'DJI' THEN 'DJ' WHEN 'DMA' THEN 'DM' WHEN 'DNK' THEN 'DK' WHEN 'DOM' THEN 'DO' WHEN 'DZA' THEN 'DZ' WHEN 'ECU' THEN 'EC'
+	WHEN 'EGY' THEN 'EG' WHEN 'ERI' THEN 'ER' WHEN 'ESH' THEN 'EH' WHEN 'ESP' THEN 'ES' WHEN 'EST' THEN 'EE' WHEN 'ETH' THEN 'ET'
+	WHEN 'FIN' THEN 'FI' WHEN 'FJI' THEN 'FJ' WHEN 'FLK' THEN 'FK' WHEN 'FRA' THEN 'FR' WHEN 'FRO' THEN 'FO' WHEN 'FSM' THEN 'FM'
+	WHEN 'GAB' THEN 'GA' WHEN 'GBR' THEN 'GB' WHEN 'GEO' THEN 'GE' WHEN 'GGY' THEN 'GG' WHEN 'GHA' THEN 'GH' WHEN 'GIB' THEN 'GI'
+	WHEN 'GIN' THEN 'GN' WHEN 'GLP' THEN 'GP' WHEN 'GMB' THEN 'GM' WHEN 'GNB' THEN 'GW' WHEN 'GNQ' THEN 'GQ' WHEN 'GRC' THEN 'GR'
+	WHEN 'GRD' THEN 'GD' WHEN 'GRL' THEN 'GL' WHEN 'GTM' THEN 'GT' WHEN 'GUF' THEN 'GF' WHEN 'GUM' THEN 'GU' WHEN 'GUY' THEN 'GY'
+	WHEN 'HKG' THEN 'HK' WHEN 'HMD' THEN 'HM' WHEN 'HND' THEN 'HN' WHEN 'HRV' THEN 'HR' WHEN 'HTI' THEN 'HT' WHEN 'HUN' THEN 'HU'
+	WHEN 'IDN' THEN 'ID' WHEN 'IMN' THEN 'IM' WHEN 'IND' THEN 'IN' WHEN 'IOT' THEN 'IO' WHEN 'IRL' THEN 'IE' WHEN 'IRN' THEN 'IR'
+	WHEN 'IRQ' THEN 'IQ' WHEN 'ISL' THEN 'IS' WHEN 'ISR' THEN 'IL' WHEN 'ITA' THEN 'IT' WHEN 'JAM' THEN 'JM' WHEN 'JEY' THEN 'JE'
+	WHEN 'JOR' THEN 'JO' WHEN 'JPN' THEN 'JP' WHEN 'KAZ' THEN 'KZ' WHEN 'KEN' THEN 'KE' WHEN 'KGZ' THEN 'KG' WHEN 'KHM' THEN 'KH'
+	WHEN 'KIR' THEN 'KI' WHEN 'KNA' THEN 'KN' WHEN 'KOR' THEN 'KR' WHEN 'KWT' THEN 'KW' WHEN 'LAO' THEN 'LA' WHEN 'LBN' THEN 'LB'
+	WHEN 'LBR' THEN 'LR' WHEN 'LBY' THEN 'LY' WHEN 'LCA' THEN 'LC' WHEN 'LIE' THEN 'LI' WHEN 'LKA' THEN 'LK' WHEN 'LSO' THEN 'LS'
+	WHEN 'LTU' THEN 'LT' WHEN 'LUX' THEN 'LU' WHEN 'LVA' THEN 'LV' WHEN 'MAC' THEN 'MO' WHEN 'MAF' THEN 'MF' WHEN 'MAR' THEN 'MA'
+	WHEN 'MCO' THEN 'MC' WHEN 'MDA' THEN 'MD' WHEN 'MDG' THEN 'MG' WHEN 'MDV' THEN 'MV' WHEN 'MEX' THEN 'MX' WHEN 'MHL' THEN 'MH'
+	WHEN 'MKD' THEN 'MK' WHEN 'MLI' THEN 'ML' WHEN 'MLT' THEN 'MT' WHEN 'MMR' THEN 'MM' WHEN 'MNE' THEN 'ME' WHEN 'MNG' THEN 'MN'
+	WHEN 'MNP' THEN 'MP' WHEN 'MOZ' THEN 'MZ' WHEN 'MRT' THEN 'MR' WHEN 'MSR' THEN 'MS' WHEN 'MTQ' THEN 'MQ' WHEN 'MUS' THEN 'MU'
+	WHEN 'MWI' THEN 'MW' WHEN 'MYS' THEN 'MY' WHEN 'MYT' THEN 'YT' WHEN 'NAM' THEN 'NA' WHEN 'NCL' THEN 'NC' WHEN 'NER' THEN 'NE'
+	WHEN 'NFK' THEN 'NF' WHEN 'NGA' THEN 'NG' WHEN 'NIC' THEN 'NI' WHEN 'NIU' THEN 'NU' WHEN 'NLD' THEN 'NL' WHEN 'NOR' THEN 'NO'
+	WHEN 'NPL' THEN 'NP' WHEN 'NRU' THEN 'NR' WHEN 'NZL' THEN 'NZ' WHEN 'OMN' THEN 'OM' WHEN 'PAK' THEN 'PK' WHEN 'PAN' THEN 'PA'
+	WHEN 'PCN' THEN 'PN' WHEN 'PER' THEN 'PE' WHEN 'PHL' THEN 'PH' WHEN 'PLW' THEN 'PW' WHEN 'PNG' THEN 'PG' WHEN 'POL' THEN 'PL'
+	WHEN 'PRI' THEN 'PR' WHEN 'PRK' THEN 'KP' WHEN 'PRT' THEN 'PT' WHEN 'PRY' THEN 'PY' WHEN 'PSE' THEN 'PS' WHEN 'PYF' THEN 'PF'
+	WHEN 'QAT' THEN 'QA' WHEN 'REU' THEN 'RE' WHEN 'ROU' THEN 'RO' WHEN 'RUS' THEN 'RU' WHEN 'RWA' THEN 'RW' WHEN 'SAU' THEN 'SA'
+	WHEN 'SDN' THEN 'SD' WHEN 'SEN' THEN 'SN' WHEN 'SGP' THEN 'SG' WHEN 'SGS' THEN 'GS' WHEN 'SHN' THEN 'SH' WHEN 'SJM' THEN 'SJ'
+	WHEN 'SLB' THEN 'SB' WHEN 'SLE' THEN 'SL' WHEN 'SLV' THEN 'SV' WHEN 'SMR' THEN 'SM' WHEN 'SOM' THEN 'SO' WHEN 'SPM' THEN 'PM'
+	WHEN 'SRB' THEN 'RS' WHEN 'SSD' THEN 'SS' WHEN 'STP' THEN 'ST' WHEN 'SUR' THEN 'SR' WHEN 'SVK' THEN 'SK' WHEN 'SVN' THEN 'SI'
+	WHEN 'SWE' THEN 'SE' WHEN 'SWZ' THEN 'SZ' WHEN 'SXM' THEN 'SX' WHEN 'SYC' THEN 'SC' WHEN 'SYR' THEN 'SY' WHEN 'TCA' THEN 'TC'
+	WHEN 'TCD' THEN 'TD' WHEN 'TGO' THEN 'TG' WHEN 'THA' THEN 'TH' WHEN 'TJK' THEN 'TJ' WHEN 'TKL' THEN 'TK' WHEN 'TKM' THEN 'TM'
+	WHEN 'TLS' THEN 'TL' WHEN 'TON' THEN 'TO' WHEN 'TTO' THEN 'TT' WHEN 'TUN' THEN 'TN' WHEN 'TUR' THEN 'TR' WHEN 'TUV' THEN 'TV'
+	WHEN 'TWN' THEN 'TW' WHEN 'TZA' THEN 'TZ' WHEN 'UGA' THEN 'UG' WHEN 'UK' THEN 'GB' WHEN 'UKR' THEN 'UA' WHEN 'UMI' THEN 'UM'
+	WHEN 'URY' THEN 'UY' WHEN 'USA' THEN 'US' WHEN 'UZB' THEN 'UZ' WHEN 'VAT' THEN 'VA' WHEN 'VCT' THEN 'VC' WHEN 'VEN' THEN 'VE'
+	WHEN 'VGB' THEN 'VG' WHEN 'VIR' THEN 'VI' WHEN 'VNM' THEN 'VN' WHEN 'VUT' THEN 'VU' WHEN 'WLF' THEN 'WF' WHEN 'WSM' THEN 'WS'
+	WHEN 'YEM' THEN 'YE' WHEN 'ZAF' THEN 'ZA' WHEN 'ZMB' THEN 'ZM' WHEN 'ZWE' THEN 'ZW'
+	ELSE country END
+WHERE country IN (
+	'ABW', 'AFG', 'AGO', 'AIA', 'ALA', 'ALB', 'AND', 'ARE', 'ARG', 'ARM', 'ASM', 'ATA', 'ATF', 'ATG', 'AUS', 'AUT',
+	'AZE', 'BDI', 'BEL', 'BEN', 'BES', 'BFA', 'BGD', 'BGR', 'BHR', 'BHS', 'BIH', 'BLM', 'BLR', 'BLZ', 'BMU', 'BOL',
+	'BRA', 'BRB', 'BRN', 'BTN', 'BVT', 'BWA', 'CAF', 'CAN', 'CCK', 'CHE', 'CHL', 'CHN', 'CIV', 'CMR', 'COD', 'COG',
+	'COK', 'COL', 'COM', 'CPV', 'CRI', 'CUB', 'CUW', 'CXR', 'CYM', 'CYP', 'CZE', 'DEU', 'DJI', 'DMA', 'DNK', 'DOM',
+	'DZA', 'ECU', 'EGY', 'ERI', 'ESH', 'ESP', 'EST', 'ETH', 'FIN', 'FJI', 'FLK', 'FRA', 'FRO', 'FSM', 'GAB', 'GBR',
+	'GEO', 'GGY', 'GHA', 'GIB', 'GIN', 'GLP', 'GMB', 'GNB', 'GNQ', 'GRC', 'GRD', 'GRL', 'GTM', 'GUF', 'GUM', 'GUY',
+	'HKG', 'HMD', 'HND', 'HRV', 'HTI', 'HUN', 'IDN', 'IMN', 'IND', 'IOT', 'IRL', 'IRN', 'IRQ', 'ISL', 'ISR', 'ITA',
+	'JAM', 'JEY', 'JOR', 'JPN', 'KAZ', 'KEN', 'KGZ', 'KHM', 'KIR', 'KNA', 'KOR', 'KWT', 'LAO', 'LBN', 'LBR', 'LBY',
+	'LCA', 'LIE', 'LKA', 'LSO', 'LTU', 'LUX', 'LVA', 'MAC', 'MAF', 'MAR', 'MCO', 'MDA', 'MDG', 'MDV', 'MEX', 'MHL',
+	'MKD', 'MLI', 'MLT', 'MMR', 'MNE', 'MNG', 'MNP', 'MOZ', 'MRT', 'MSR', 'MTQ', 'MUS', 'MWI', 'MYS', 'MYT', 'NAM',
+	'NCL', 'NER', 'NFK', 'NGA', 'NIC', 'NIU', 'NLD', 'NOR', 'NPL', 'NRU', 'NZL', 'OMN', 'PAK', 'PAN', 'PCN', 'PER',
+	'PHL', 'PLW', 'PNG', 'POL', 'PRI', 'PRK', 'PRT', 'PRY', 'PSE', 'PYF', 'QAT', 'REU', 'ROU', 'RUS', 'RWA', 'SAU',
+	'SDN', 'SEN', 'SGP', 'SGS', 'SHN', 'SJM', 'SLB', 'SLE', 'SLV', 'SMR', 'SOM', 'SPM', 'SRB', 'SSD', 'STP', 'SUR',
+	'SVK', 'SVN', 'SWE', 'SWZ', 'SXM', 'SYC', 'SYR', 'TCA', 'TCD', 'TGO', 'THA', 'TJK', 'TKL', 'TKM', 'TLS', 'TON',
+	'TTO', 'TUN', 'TUR', 'TUV', 'TWN', 'TZA', 'UGA', 'UK', 'UKR', 'UMI', 'URY', 'USA', 'UZB', 'VAT', 'VCT', 'VEN',
+	'VGB', 'VIR', 'VNM', 'VUT', 'WLF', 'WSM', 'YEM', 'ZAF', 'ZMB', 'ZWE'
+)`
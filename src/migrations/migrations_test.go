@@ -0,0 +1,17 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationSet(t *testing.T) {
+	for i, migration := range all {
+		require.Equal(t, i+1, migration.Version, "migrations must be numbered consecutively")
+		require.NotEmpty(t, migration.Name)
+		require.NotEmpty(t, migration.Up.Postgres)
+		require.NotEmpty(t, migration.Down.Postgres)
+		require.Equal(t, migration.Up.SQLite == "", migration.Down.SQLite == "", "SQLite migrations must be reversible")
+	}
+}
@@ -0,0 +1,205 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/beldin0/users/src/logging"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// lockID identifies the advisory lock held while migrating,
+// so that replicas starting together do not apply the same migration twice
+const lockID = 7081996
+
+// dialect captures how migrations are managed in each supported database
+type dialect struct {
+	createMigrations string
+	// lock and unlock are empty where the database has no advisory locks
+	lock   string
+	unlock string
+	// statement selects the migration statement for the database
+	statement func(Statements) string
+}
+
+var dialects = map[string]dialect{
+	"postgres": {
+		createMigrations: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		lock:      `SELECT pg_advisory_lock($1)`,
+		unlock:    `SELECT pg_advisory_unlock($1)`,
+		statement: func(s Statements) string { return s.Postgres },
+	},
+	// SQLite serves single node deployments, so there are no replicas to lock out
+	"sqlite3": {
+		createMigrations: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		statement: func(s Statements) string { return s.SQLite },
+	},
+}
+
+// Status reports whether a migration has been applied
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies the migration set to a database
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+// New returns a Migrator for the provided database
+func New(db *sqlx.DB) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: all,
+	}
+}
+
+// Up applies all pending migrations in order, returning the number applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.locked(ctx, func(conn *sql.Conn, d dialect, done map[int]time.Time) error {
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			err := inTx(ctx, conn, d.statement(migration.Up),
+				m.db.Rebind(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`), migration.Version, migration.Name)
+			if err != nil {
+				return errors.Wrapf(err, "applying migration %d_%s", migration.Version, migration.Name)
+			}
+			logging.NewLogger().Sugar().
+				With("version", migration.Version).
+				With("name", migration.Name).
+				Info("migration applied")
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the most recently applied migrations, up to steps of them
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+	err := m.locked(ctx, func(conn *sql.Conn, d dialect, done map[int]time.Time) error {
+		for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			err := inTx(ctx, conn, d.statement(migration.Down),
+				m.db.Rebind(`DELETE FROM schema_migrations WHERE version=?`), migration.Version)
+			if err != nil {
+				return errors.Wrapf(err, "reverting migration %d_%s", migration.Version, migration.Name)
+			}
+			logging.NewLogger().Sugar().
+				With("version", migration.Version).
+				With("name", migration.Name).
+				Info("migration reverted")
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration and when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(conn *sql.Conn, d dialect, done map[int]time.Time) error {
+		for _, migration := range m.migrations {
+			status := Status{Migration: migration}
+			if at, ok := done[migration.Version]; ok {
+				status.AppliedAt = &at
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// locked runs f on a single connection holding the migration advisory lock,
+// passing the versions that have already been applied
+func (m *Migrator) locked(ctx context.Context, f func(*sql.Conn, dialect, map[int]time.Time) error) error {
+	d, ok := dialects[m.db.DriverName()]
+	if !ok {
+		return fmt.Errorf("migrations are not supported for %s", m.db.DriverName())
+	}
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if d.lock != "" {
+		if _, err := conn.ExecContext(ctx, d.lock, lockID); err != nil {
+			return errors.Wrap(err, "acquiring migration lock")
+		}
+		defer conn.ExecContext(context.Background(), d.unlock, lockID)
+	}
+
+	if _, err := conn.ExecContext(ctx, d.createMigrations); err != nil {
+		return errors.Wrap(err, "creating schema_migrations")
+	}
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	done := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return err
+		}
+		done[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	return f(conn, d, done)
+}
+
+// inTx runs the migration statement and the accompanying bookkeeping statement in one transaction
+// An empty migration statement only records the bookkeeping
+func inTx(ctx context.Context, conn *sql.Conn, statement, bookkeeping string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if statement != "" {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// String formats the status for display by the migrate command
+func (s Status) String() string {
+	applied := "pending"
+	if s.AppliedAt != nil {
+		applied = "applied " + s.AppliedAt.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%d_%s\t%s", s.Version, s.Name, applied)
+}
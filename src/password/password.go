@@ -0,0 +1,68 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Parameters for new hashes. The parameters are encoded alongside each hash,
+// so these can be changed without invalidating existing passwords.
+const (
+	iterations = 1
+	memory     = 64 * 1024
+	threads    = 4
+	keyLen     = 32
+	saltLen    = 16
+)
+
+// ErrMalformedHash is returned when a stored hash is not in the encoded argon2id format
+var ErrMalformedHash = errors.New("malformed password hash")
+
+var encoding = base64.RawStdEncoding
+
+// Hash returns a salted argon2id hash of the password, encoded as
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<hash>
+func Hash(password string) (string, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, iterations, memory, threads, keyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, memory, iterations, threads,
+		encoding.EncodeToString(salt), encoding.EncodeToString(key)), nil
+}
+
+// Verify reports whether the password matches the encoded hash,
+// using the parameters recorded in the hash
+func Verify(encoded, password string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, ErrMalformedHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, ErrMalformedHash
+	}
+	var m, t uint32
+	var p uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &m, &t, &p); err != nil {
+		return false, ErrMalformedHash
+	}
+	salt, err := encoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrMalformedHash
+	}
+	key, err := encoding.DecodeString(parts[5])
+	if err != nil {
+		return false, ErrMalformedHash
+	}
+	actual := argon2.IDKey([]byte(password), salt, t, m, p, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, actual) == 1, nil
+}
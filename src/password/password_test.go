@@ -0,0 +1,29 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	hash, err := Hash("correct horse")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=1,p=4$"))
+
+	other, err := Hash("correct horse")
+	require.NoError(t, err)
+	require.NotEqual(t, hash, other) // assert that hashes are salted
+
+	ok, err := Verify(hash, "correct horse")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = Verify(hash, "battery staple")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, err = Verify("pass", "pass")
+	require.Equal(t, ErrMalformedHash, err)
+}
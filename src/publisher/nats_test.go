@@ -0,0 +1,86 @@
+package publisher
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userservice"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNATS accepts a single client, replying to each PING with reply and sending the received messages to msgs
+func fakeNATS(t *testing.T, reply string, msgs chan<- string) net.Listener {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "PUB":
+				size, _ := strconv.Atoi(fields[2])
+				payload := make([]byte, size+2)
+				io.ReadFull(r, payload)
+				e := &user.UserEvent{}
+				proto.Unmarshal(payload[:size], e)
+				msgs <- fields[1] + " " + strconv.FormatInt(e.Id, 10)
+			case "PING":
+				conn.Write([]byte(reply))
+			}
+		}
+	}()
+	return lis
+}
+
+func TestNATS(t *testing.T) {
+	msgs := make(chan string, 2)
+	lis := fakeNATS(t, "PONG\r\n", msgs)
+	defer lis.Close()
+	p, err := NewNATS("nats://"+lis.Addr().String(), "users")
+	require.NoError(t, err)
+	defer p.Close()
+
+	ctx := context.Background()
+	require.NoError(t, p.Publish(ctx, &user.UserEvent{Id: 1, Event: &user.UserEvent_Created{Created: &user.UserCreated{}}}))
+	require.NoError(t, p.Publish(ctx, &user.UserEvent{Id: 2, Event: &user.UserEvent_Deleted{Deleted: &user.UserDeleted{}}}))
+	require.Equal(t, "users.UserCreated 1", <-msgs)
+	require.Equal(t, "users.UserDeleted 2", <-msgs)
+
+	t.Run("server error", func(t *testing.T) {
+		lis := fakeNATS(t, "-ERR 'Permissions Violation'\r\n", make(chan string, 1))
+		defer lis.Close()
+		p, err := NewNATS("nats://"+lis.Addr().String(), "users")
+		require.NoError(t, err)
+		defer p.Close()
+		err = p.Publish(ctx, &user.UserEvent{Id: 1})
+		require.Error(t, err)
+		require.False(t, errors.Is(err, userservice.ErrSinkUnavailable))
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		lis.Close()
+		p, err := NewNATS("nats://"+lis.Addr().String(), "users")
+		require.NoError(t, err)
+		require.True(t, errors.Is(p.Publish(ctx, &user.UserEvent{Id: 1}), userservice.ErrSinkUnavailable))
+	})
+}
@@ -0,0 +1,57 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userservice"
+	"github.com/golang/protobuf/jsonpb"
+)
+
+// Writer publishes events as lines of JSON, e.g. to stdout for log aggregation or to a file
+type Writer struct {
+	mu        sync.Mutex
+	w         io.Writer
+	marshaler jsonpb.Marshaler
+	// file is synced after every event, so that published events survive a crash
+	file *os.File
+}
+
+// NewWriter returns a Writer publishing events to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// NewFile returns a Writer appending events to the file at path, which is created if it does not exist
+func NewFile(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	p := NewWriter(f)
+	p.file = f
+	return p, nil
+}
+
+// Publish writes the event as a single line of JSON
+func (p *Writer) Publish(ctx context.Context, e *user.UserEvent) error {
+	line, err := p.marshaler.MarshalToString(e)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := io.WriteString(p.w, line+"\n"); err != nil {
+		return fmt.Errorf("%w: %v", userservice.ErrSinkUnavailable, err)
+	}
+	if p.file != nil {
+		if err := p.file.Sync(); err != nil {
+			return fmt.Errorf("%w: %v", userservice.ErrSinkUnavailable, err)
+		}
+	}
+	return nil
+}
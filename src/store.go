@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/beldin0/users/src/logging"
+	"github.com/beldin0/users/src/migrations"
+	"github.com/beldin0/users/src/userservice"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is the SQLite driver with the similarity function of pg_trgm, for fuzzy searches
+const sqliteDriver = "sqlite3_users"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("similarity", userservice.Similarity, true)
+		},
+	})
+}
+
+// newStore returns the Store selected by the configured driver,
+// migrating the database schema where there is one.
+// A Postgres store listens for the changes made by other replicas until ctx is done.
+func newStore(ctx context.Context, c config) (userservice.Store, error) {
+	if c.Driver == "memory" {
+		return userservice.NewMemoryStore(), nil
+	}
+	db, err := openDB(c)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := migrations.New(db).Up(ctx); err != nil {
+		return nil, err
+	}
+	if c.Driver == "sqlite" {
+		return userservice.NewSQLiteStore(db), nil
+	}
+	store := userservice.NewPostgresStore(db)
+	go func() {
+		if err := store.Listen(ctx, c.ConnString()); err != nil {
+			logging.NewLogger().Sugar().
+				With("error", err).
+				Warn("problem listening for changes from other replicas")
+		}
+	}()
+	return store, nil
+}
+
+// openDB connects to the database selected by the configured driver
+func openDB(c config) (*sqlx.DB, error) {
+	switch c.Driver {
+	case "postgres":
+		return sqlx.Connect("postgres", c.ConnString())
+	case "sqlite":
+		raw, err := sql.Open(sqliteDriver, c.SQLitePath)
+		if err != nil {
+			return nil, err
+		}
+		// named as the underlying driver, which selects the bind type and migrations
+		db := sqlx.NewDb(raw, "sqlite3")
+		if err := db.Ping(); err != nil {
+			db.Close()
+			return nil, err
+		}
+		// SQLite allows a single writer, so queue queries rather than failing with "database is locked".
+		// This also keeps an in-memory database alive, as it only exists for the life of its connection.
+		db.SetMaxOpenConns(1)
+		return db, nil
+	}
+	return nil, fmt.Errorf("unknown DB_DRIVER %q, expected postgres, sqlite or memory", c.Driver)
+}
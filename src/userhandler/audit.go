@@ -0,0 +1,50 @@
+package userhandler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/beldin0/users/src/userio"
+	"github.com/beldin0/users/src/userservice"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"google.golang.org/grpc/metadata"
+)
+
+// unknownActor is recorded in the audit log for requests that do not say who made them
+const unknownActor = "unknown"
+
+// audit returns who is making the request for the audit log, from the x-actor and x-request-id
+// metadata, which the gateway populates from the X-Actor and X-Request-Id headers.
+// A request id is generated for requests without one.
+func audit(ctx context.Context) userservice.Audit {
+	a := userservice.Audit{Actor: unknownActor}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-actor"); len(values) > 0 && values[0] != "" {
+			a.Actor = values[0]
+		}
+		if values := md.Get("x-request-id"); len(values) > 0 {
+			a.RequestID = values[0]
+		}
+	}
+	if a.RequestID == "" {
+		id := make([]byte, 16)
+		rand.Read(id)
+		a.RequestID = hex.EncodeToString(id)
+	}
+	return a
+}
+
+// toTime converts an optional proto timestamp, returning the zero time if it is not set
+func toTime(ts *timestamp.Timestamp) (time.Time, error) {
+	if ts == nil {
+		return time.Time{}, nil
+	}
+	t, err := ptypes.Timestamp(ts)
+	if err != nil {
+		return time.Time{}, userio.InvalidArgumentError("INVALID_TIMESTAMP", "invalid timestamp")
+	}
+	return t, nil
+}
@@ -0,0 +1,24 @@
+package userhandler
+
+import (
+	"context"
+
+	"github.com/beldin0/users/src/logging"
+	pb "github.com/beldin0/users/src/user"
+)
+
+func (h *userHandler) BatchGet(ctx context.Context, req *pb.BatchGetRequest) (*pb.BatchGetResponse, error) {
+	users, err := h.service.BatchGet(req.Ids)
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("ids", len(req.Ids)).
+			With("error", err).
+			Warn("error getting users")
+		return nil, toStatus(err)
+	}
+	results := make([]*pb.BatchGetResult, len(users))
+	for i, u := range users {
+		results[i] = &pb.BatchGetResult{Id: req.Ids[i], User: u, Missing: u == nil}
+	}
+	return &pb.BatchGetResponse{Results: results}, nil
+}
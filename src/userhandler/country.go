@@ -0,0 +1,29 @@
+package userhandler
+
+import (
+	"context"
+	"strings"
+
+	"github.com/beldin0/users/src/country"
+	pb "github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userio"
+)
+
+func (h *userHandler) ListCountries(ctx context.Context, req *pb.ListCountriesRequest) (*pb.ListCountriesResponse, error) {
+	countries := []*pb.Country{}
+	for _, c := range country.All() {
+		if req.Region != "" && !strings.EqualFold(c.Region, strings.TrimSpace(req.Region)) {
+			continue
+		}
+		countries = append(countries, &pb.Country{
+			Alpha2: c.Alpha2,
+			Alpha3: c.Alpha3,
+			Name:   c.Name,
+			Region: c.Region,
+		})
+	}
+	if len(countries) == 0 {
+		return nil, toStatus(userio.InvalidArgumentError("INVALID_REGION", "there are no countries in region "+req.Region))
+	}
+	return &pb.ListCountriesResponse{Countries: countries}, nil
+}
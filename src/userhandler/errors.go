@@ -0,0 +1,54 @@
+package userhandler
+
+import (
+	"errors"
+
+	"github.com/beldin0/users/src/userservice"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain groups the reasons reported in error details
+const errorDomain = "users.beldin0.github.com"
+
+var codesByKind = map[userservice.Kind]codes.Code{
+	userservice.Internal:           codes.Internal,
+	userservice.InvalidArgument:    codes.InvalidArgument,
+	userservice.NotFound:           codes.NotFound,
+	userservice.AlreadyExists:      codes.AlreadyExists,
+	userservice.Unauthenticated:    codes.Unauthenticated,
+	userservice.Unavailable:        codes.Unavailable,
+	userservice.FailedPrecondition: codes.FailedPrecondition,
+}
+
+// toStatus translates an error from the userservice into a gRPC status, which the gateway
+// reports with the matching HTTP status code. The status carries the error's reason as an
+// ErrorInfo detail, along with a BadRequest detail listing any invalid fields. Unclassified errors
+// are reported as Internal without their message, so that database errors are never returned to clients.
+func toStatus(err error) error {
+	var e *userservice.Error
+	if !errors.As(err, &e) {
+		e = userservice.ErrInternal
+	}
+	st := status.New(codesByKind[e.Kind], e.Message)
+	details := []proto.Message{&errdetails.ErrorInfo{
+		Reason: e.Reason,
+		Domain: errorDomain,
+	}}
+	if len(e.Violations) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, v := range e.Violations {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Description: v.Description,
+			})
+		}
+		details = append(details, badRequest)
+	}
+	if detailed, err := st.WithDetails(details...); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
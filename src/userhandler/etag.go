@@ -0,0 +1,28 @@
+package userhandler
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// ifMatchKeys are the metadata keys an etag can be sent in instead of the request body:
+// the gateway forwards an If-Match header with its grpcgateway- prefix
+var ifMatchKeys = []string{"grpcgateway-if-match", "if-match"}
+
+// ifMatch returns etag if it is set, otherwise the etag sent as an If-Match header or metadata
+func ifMatch(ctx context.Context, etag string) string {
+	if etag != "" {
+		return etag
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, key := range ifMatchKeys {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package userhandler
+
+import (
+	"github.com/beldin0/users/src/logging"
+	pb "github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userio"
+	"github.com/beldin0/users/src/userservice"
+)
+
+func (h *userHandler) Export(req *pb.ExportRequest, stream pb.UserService_ExportServer) error {
+	mask := req.ReadMask.GetPaths()
+	if _, err := userio.ExportFields(mask); err != nil {
+		return toStatus(err)
+	}
+	search, err := buildExport(req)
+	if err != nil {
+		return toStatus(err)
+	}
+	err = h.service.Export(search, mask, stream.Send)
+	if err != nil && stream.Context().Err() == nil {
+		logging.NewLogger().Sugar().
+			With("request", req).
+			With("error", err).
+			Warn("error exporting users")
+		return toStatus(err)
+	}
+	return err
+}
+
+// buildExport returns the SearchOptions selecting the users to export
+func buildExport(req *pb.ExportRequest) (*userservice.SearchOptions, error) {
+	return buildSearch(&pb.SearchRequest{
+		FirstName:      req.FirstName,
+		LastName:       req.LastName,
+		Nickname:       req.Nickname,
+		Email:          req.Email,
+		Country:        req.Country,
+		ShowDeleted:    req.ShowDeleted,
+		Filter:         req.Filter,
+		FirstNameMatch: req.FirstNameMatch,
+		LastNameMatch:  req.LastNameMatch,
+		NicknameMatch:  req.NicknameMatch,
+		EmailMatch:     req.EmailMatch,
+		CountryMatch:   req.CountryMatch,
+	})
+}
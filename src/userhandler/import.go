@@ -0,0 +1,69 @@
+package userhandler
+
+import (
+	"context"
+	"io"
+
+	"github.com/beldin0/users/src/logging"
+	pb "github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userio"
+	"google.golang.org/grpc/metadata"
+)
+
+func (h *userHandler) Import(stream pb.UserService_ImportServer) error {
+	ctx := stream.Context()
+	// the HTTP upload reads users from CSV or JSONL, numbering the lines of the file
+	reader, ok := stream.(userio.ImportReader)
+	if !ok {
+		reader = &streamReader{stream: stream}
+	}
+	summary, err := h.service.Import(&validatingReader{reader}, dryRun(ctx), audit(ctx))
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("error", err).
+			Warn("error importing users")
+		return toStatus(err)
+	}
+	return stream.SendAndClose(summary)
+}
+
+// dryRun reports whether the x-dry-run metadata asks for the import to only be checked
+func dryRun(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("x-dry-run")
+	return len(values) > 0 && values[0] == "true"
+}
+
+// streamReader reads the users sent on an Import stream, numbering them from 1 as their lines
+type streamReader struct {
+	stream pb.UserService_ImportServer
+	line   int
+}
+
+func (r *streamReader) Read() (*pb.User, int, error) {
+	u, err := r.stream.Recv()
+	if err == io.EOF {
+		return nil, 0, io.EOF
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	r.line++
+	return u, r.line, nil
+}
+
+// validatingReader rejects the rows holding invalid users, as Add does
+type validatingReader struct {
+	userio.ImportReader
+}
+
+func (r *validatingReader) Read() (*pb.User, int, error) {
+	u, line, err := r.ImportReader.Read()
+	if err != nil {
+		return u, line, err
+	}
+	if err := validateUser(u, nil); err != nil {
+		return nil, line, &userio.RowError{Line: line, Err: err}
+	}
+	return u, line, nil
+}
@@ -3,56 +3,46 @@ package userhandler
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/beldin0/users/src/logging"
 	pb "github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userio"
 	"github.com/beldin0/users/src/userservice"
 	"github.com/golang/protobuf/ptypes/empty"
-	"github.com/jmoiron/sqlx"
 )
 
 type userHandler struct {
 	service *userservice.Service
+	// done ends Watch streams, which would otherwise hold up a graceful shutdown
+	done <-chan struct{}
 }
 
-// New returns a userHandler instance
-func New(db *sqlx.DB) pb.UserServiceServer {
-	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS users (
-		id SERIAL PRIMARY KEY,
-		first_name VARCHAR(50),
-		first_name_lower VARCHAR(50),
-		last_name VARCHAR(50),
-		last_name_lower VARCHAR(50),
-		nickname VARCHAR(30),
-		nickname_lower VARCHAR(30) UNIQUE,
-		password VARCHAR(32),
-		email VARCHAR(50) UNIQUE,
-		country VARCHAR(3)
-	)`)
-	if err != nil {
-		logging.NewLogger().Sugar().
-			With("error", err).
-			Error("problem setting up database")
-		panic(err)
-	}
+// New returns a userHandler instance serving users from the provided Store.
+// Open Watch streams are ended when ctx is done.
+func New(ctx context.Context, store userservice.Store) pb.UserServiceServer {
 	return &userHandler{
-		service: userservice.New(db),
+		service: userservice.New(store),
+		done:    ctx.Done(),
 	}
 }
 
 func (h *userHandler) Add(ctx context.Context, user *pb.User) (*pb.User, error) {
-	err := h.service.Add(user)
+	if err := validateUser(user, nil); err != nil {
+		return nil, toStatus(err)
+	}
+	err := h.service.Add(user, audit(ctx))
 	if errors.Is(err, userservice.ErrDuplicate) {
 		logging.NewLogger().Sugar().
 			With("error", err).
 			Info("duplicate user add request")
-		return nil, err
+		return nil, toStatus(err)
 	}
 	if err != nil {
 		logging.NewLogger().Sugar().
 			With("error", err).
 			Warn("database error")
-		return nil, err
+		return nil, toStatus(err)
 	}
 	logging.NewLogger().Sugar().
 		With("user", user).
@@ -60,87 +50,221 @@ func (h *userHandler) Add(ctx context.Context, user *pb.User) (*pb.User, error)
 	return user, nil
 }
 
-func (h *userHandler) Search(ctx context.Context, user *pb.User) (*pb.UsersResponse, error) {
-	search, err := buildSearch(user)
+func (h *userHandler) Search(ctx context.Context, req *pb.SearchRequest) (*pb.UsersResponse, error) {
+	search, err := buildSearch(req)
 	if err != nil {
-		logging.NewLogger().Sugar().
-			With("user", user).
-			With("error", err).
-			Warn("error building search")
-		return nil, err
+		return nil, toStatus(err)
 	}
-	users, err := h.service.Get(search)
+	results, err := h.service.Search(search, userservice.Page{
+		Size:    int(req.PageSize),
+		Token:   req.PageToken,
+		OrderBy: req.OrderBy,
+	})
 	if err != nil {
 		logging.NewLogger().Sugar().
-			With("user", user).
+			With("request", req).
 			With("error", err).
 			Warn("error executing search")
-		return nil, err
+		return nil, toStatus(err)
 	}
-	return &pb.UsersResponse{Users: users}, err
+	return &pb.UsersResponse{
+		Users:         results.Users,
+		NextPageToken: results.NextToken,
+		TotalSize:     int32(results.Total),
+		Scores:        results.Scores,
+	}, nil
 }
 
-func (h *userHandler) Delete(ctx context.Context, id *pb.UserId) (*empty.Empty, error) {
-	err := h.service.Delete(id.Id)
+func (h *userHandler) Delete(ctx context.Context, req *pb.DeleteRequest) (*empty.Empty, error) {
+	err := h.service.Delete(req.Id, ifMatch(ctx, req.Etag), audit(ctx))
+	if errors.Is(err, userservice.ErrNotFound) || errors.Is(err, userservice.ErrEtagMismatch) {
+		return nil, toStatus(err)
+	}
 	if err != nil {
 		logging.NewLogger().Sugar().
 			With("error", err).
 			Warn("database error")
+		return nil, toStatus(err)
 	}
-	return &empty.Empty{}, err
+	return &empty.Empty{}, nil
 }
 
 func (h *userHandler) Get(ctx context.Context, id *pb.UserId) (*pb.User, error) {
-	user, err := h.service.Get(userservice.Get(id.Id))
+	user, err := h.service.GetByID(id.Id)
+	if errors.Is(err, userservice.ErrNotFound) {
+		return nil, toStatus(err)
+	}
 	if err != nil {
 		logging.NewLogger().Sugar().
 			With("id", id.Id).
 			With("error", err).
 			Warn("server error")
-		return nil, err
-	}
-	if len(user) == 0 {
-		return &pb.User{}, nil
+		return nil, toStatus(err)
 	}
-	return user[0], nil
+	return user, nil
 }
 
 func (h *userHandler) Modify(ctx context.Context, user *pb.User) (*pb.User, error) {
-	err := h.service.Modify(user.Id, user)
+	if err := validateUser(user, nil); err != nil {
+		return nil, toStatus(err)
+	}
+	user.Etag = ifMatch(ctx, user.Etag)
+	stored, err := h.service.Modify(user.Id, user, audit(ctx))
+	if errors.Is(err, userservice.ErrNotFound) || errors.Is(err, userservice.ErrEtagMismatch) {
+		return nil, toStatus(err)
+	}
 	if err != nil {
 		logging.NewLogger().Sugar().
 			With("error", err).
 			Warn("database error")
-		return nil, err
+		return nil, toStatus(err)
+	}
+	return stored, nil
+}
+
+func (h *userHandler) Update(ctx context.Context, req *pb.UpdateRequest) (*pb.User, error) {
+	if req.User == nil {
+		return nil, toStatus(userio.InvalidArgumentError("MISSING_USER", "user must be provided"))
+	}
+	// an empty update mask is rejected by the service
+	if paths := req.UpdateMask.GetPaths(); len(paths) > 0 {
+		if err := validateUser(req.User, paths); err != nil {
+			return nil, toStatus(err)
+		}
+	}
+	req.User.Etag = ifMatch(ctx, req.User.Etag)
+	stored, err := h.service.Update(req.User.Id, req.User, req.UpdateMask.GetPaths(), audit(ctx))
+	if errors.Is(err, userservice.ErrNotFound) || errors.Is(err, userservice.ErrEtagMismatch) {
+		return nil, toStatus(err)
+	}
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("error", err).
+			Warn("database error")
+		return nil, toStatus(err)
+	}
+	return stored, nil
+}
+
+func (h *userHandler) Restore(ctx context.Context, id *pb.UserId) (*pb.User, error) {
+	user, err := h.service.Restore(id.Id, audit(ctx))
+	if errors.Is(err, userservice.ErrNotDeleted) {
+		return nil, toStatus(err)
+	}
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("id", id.Id).
+			With("error", err).
+			Warn("database error")
+		return nil, toStatus(err)
+	}
+	return user, nil
+}
+
+func (h *userHandler) Purge(ctx context.Context, req *pb.PurgeRequest) (*pb.PurgeResponse, error) {
+	purged, err := h.service.Purge(time.Duration(req.RetentionDays)*24*time.Hour, audit(ctx))
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("error", err).
+			Warn("error purging users")
+		return nil, toStatus(err)
+	}
+	return &pb.PurgeResponse{Purged: int32(purged)}, nil
+}
+
+func (h *userHandler) ListAuditEvents(ctx context.Context, req *pb.ListAuditEventsRequest) (*pb.ListAuditEventsResponse, error) {
+	start, err := toTime(req.StartTime)
+	if err != nil {
+		return nil, toStatus(err)
 	}
-	return user, err
+	end, err := toTime(req.EndTime)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	results, err := h.service.ListAuditEvents(req.UserId, start, end, userservice.Page{
+		Size:  int(req.PageSize),
+		Token: req.PageToken,
+	})
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("request", req).
+			With("error", err).
+			Warn("error listing audit events")
+		return nil, toStatus(err)
+	}
+	return &pb.ListAuditEventsResponse{
+		Events:        results.Events,
+		NextPageToken: results.NextToken,
+	}, nil
 }
 
-func buildSearch(user *pb.User) (*userservice.SearchOptions, error) {
-	search := userservice.Search()
-	var filters bool
-	if user.Country != "" {
-		search.Country(user.Country)
-		filters = true
-	}
-	if user.Email != "" {
-		search.Email(user.Email)
-		filters = true
-	}
-	if user.Nickname != "" {
-		search.Nickname(user.Nickname)
-		filters = true
-	}
-	if user.FirstName != "" {
-		search.FirstName(user.FirstName)
-		filters = true
-	}
-	if user.LastName != "" {
-		search.LastName(user.LastName)
-		filters = true
-	}
-	if !filters {
-		return nil, errors.New("no search parameters provided")
+func (h *userHandler) VerifyPassword(ctx context.Context, req *pb.VerifyPasswordRequest) (*pb.User, error) {
+	login := req.Email
+	if login == "" {
+		login = req.Nickname
+	}
+	if login == "" {
+		return nil, toStatus(userio.InvalidArgumentError("MISSING_LOGIN", "email or nickname must be provided"))
+	}
+	user, err := h.service.VerifyPassword(login, req.Password)
+	if errors.Is(err, userservice.ErrInvalidCredentials) {
+		logging.NewLogger().Sugar().
+			With("login", login).
+			Info("password verification failed")
+		return nil, toStatus(err)
+	}
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("error", err).
+			Warn("database error")
+		return nil, toStatus(err)
+	}
+	return user, nil
+}
+
+func buildSearch(req *pb.SearchRequest) (*userservice.SearchOptions, error) {
+	filter, err := userservice.ParseFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	search := userservice.Search().Filter(filter)
+	modes := []struct {
+		field string
+		mode  pb.MatchMode
+	}{
+		{"firstName", req.FirstNameMatch},
+		{"lastName", req.LastNameMatch},
+		{"nickname", req.NicknameMatch},
+		{"email", req.EmailMatch},
+		{"country", req.CountryMatch},
+	}
+	for _, m := range modes {
+		match, err := userservice.ParseMatch(m.field, m.mode)
+		if err != nil {
+			return nil, err
+		}
+		search.Match(m.field, match)
+	}
+	if req.Country != "" {
+		search.Country(req.Country)
+	}
+	if req.Email != "" {
+		search.Email(req.Email)
+	}
+	if req.Nickname != "" {
+		search.Nickname(req.Nickname)
+	}
+	if req.FirstName != "" {
+		search.FirstName(req.FirstName)
+	}
+	if req.LastName != "" {
+		search.LastName(req.LastName)
+	}
+	if req.Query != "" {
+		search.Fuzzy(req.Query)
+	}
+	if req.ShowDeleted {
+		search.IncludeDeleted()
 	}
 	return search, nil
 }
@@ -0,0 +1,128 @@
+package userhandler
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/beldin0/users/src/country"
+	pb "github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userio"
+	"github.com/golang/protobuf/proto"
+	"github.com/grpc-ecosystem/grpc-gateway/protoc-gen-swagger/options"
+)
+
+// fieldRule validates a field of a user, as declared by its openapiv2_field option in user.proto,
+// so that the rules are documented by the API alongside the fields
+type fieldRule struct {
+	name      string
+	minLength int
+	maxLength int
+	pattern   *regexp.Regexp
+	// known checks values against reference data, such as the list of countries
+	known func(string) bool
+	// description explains the pattern or reference data, following the name of the field
+	description string
+	value       func(u *pb.User) string
+}
+
+// lookups check the fields whose values must be found in reference data rather than match a pattern
+var lookups = map[string]func(string) bool{
+	"country": func(s string) bool {
+		_, ok := country.Lookup(s)
+		return ok
+	},
+}
+
+// userRules validates the fields of a user, keyed by their lowercase names without underscores
+// so that the paths of update masks can be written in either case
+var userRules = readRules()
+
+// readRules reads the rules declared for the fields of pb.User
+func readRules() map[string]fieldRule {
+	rules := map[string]fieldRule{}
+	u := &pb.User{}
+	fields := u.ProtoReflect().Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		opts := proto.MessageV1(fd.Options())
+		if !proto.HasExtension(opts, options.E_Openapiv2Field) {
+			continue
+		}
+		ext, err := proto.GetExtension(opts, options.E_Openapiv2Field)
+		if err != nil {
+			panic(fmt.Sprintf("reading the rules of %s: %v", fd.Name(), err))
+		}
+		schema := ext.(*options.JSONSchema)
+		rule := fieldRule{
+			name:        string(fd.Name()),
+			minLength:   int(schema.MinLength),
+			maxLength:   int(schema.MaxLength),
+			description: schema.Description,
+		}
+		if schema.Pattern != "" {
+			rule.pattern = regexp.MustCompile(schema.Pattern)
+		}
+		rule.known = lookups[rule.name]
+		rule.value = func(u *pb.User) string {
+			return u.ProtoReflect().Get(fd).String()
+		}
+		rules[ruleKey(rule.name)] = rule
+	}
+	return rules
+}
+
+func ruleKey(field string) string {
+	return strings.ToLower(strings.Replace(field, "_", "", -1))
+}
+
+// check returns why the value of the field is invalid, or an empty string if it is valid
+func (r fieldRule) check(u *pb.User) string {
+	value := r.value(u)
+	length := utf8.RuneCountInString(value)
+	switch {
+	case length < r.minLength && r.minLength == 1:
+		return r.name + " is required"
+	case length < r.minLength:
+		return fmt.Sprintf("%s must be at least %d characters", r.name, r.minLength)
+	case r.maxLength > 0 && length > r.maxLength:
+		return fmt.Sprintf("%s must be at most %d characters", r.name, r.maxLength)
+	case value != "" && r.pattern != nil && !r.pattern.MatchString(value),
+		value != "" && r.known != nil && !r.known(value):
+		if r.description == "" {
+			return r.name + " is invalid"
+		}
+		return r.name + " " + r.description
+	}
+	return ""
+}
+
+// validateUser checks the fields of a user against their rules, returning an INVALID_USER error listing every
+// invalid field. Only the named fields are checked, such as those of an update mask, unless fields is nil.
+func validateUser(u *pb.User, fields []string) error {
+	violations := []userio.FieldViolation{}
+	check := func(r fieldRule) {
+		if description := r.check(u); description != "" {
+			violations = append(violations, userio.FieldViolation{Field: r.name, Description: description})
+		}
+	}
+	if fields == nil {
+		// in the order of the fields of a user, so that errors are deterministic
+		all := u.ProtoReflect().Descriptor().Fields()
+		for i := 0; i < all.Len(); i++ {
+			if r, ok := userRules[ruleKey(string(all.Get(i).Name()))]; ok {
+				check(r)
+			}
+		}
+	}
+	for _, f := range fields {
+		if r, ok := userRules[ruleKey(f)]; ok {
+			check(r)
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return userio.InvalidUserError(violations)
+}
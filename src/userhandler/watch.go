@@ -0,0 +1,52 @@
+package userhandler
+
+import (
+	"context"
+
+	"github.com/beldin0/users/src/logging"
+	pb "github.com/beldin0/users/src/user"
+	"google.golang.org/grpc/metadata"
+)
+
+func (h *userHandler) Watch(req *pb.WatchRequest, stream pb.UserService_WatchServer) error {
+	watcher, err := h.service.Watch(req.ResumeToken)
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("request", req).
+			With("error", err).
+			Warn("error watching users")
+		return toStatus(err)
+	}
+	// send the headers straight away, so that clients know they are watching before the first event
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-h.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for {
+		events, err := watcher.Next(ctx)
+		if ctx.Err() != nil {
+			// the client went away or the server is shutting down
+			return nil
+		}
+		if err != nil {
+			logging.NewLogger().Sugar().
+				With("error", err).
+				Warn("error watching users")
+			return toStatus(err)
+		}
+		for _, e := range events {
+			if err := stream.Send(e); err != nil {
+				return err
+			}
+		}
+	}
+}
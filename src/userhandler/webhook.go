@@ -0,0 +1,72 @@
+package userhandler
+
+import (
+	"context"
+
+	"github.com/beldin0/users/src/logging"
+	pb "github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userservice"
+	"github.com/golang/protobuf/ptypes/empty"
+)
+
+func (h *userHandler) CreateWebhook(ctx context.Context, w *pb.Webhook) (*pb.Webhook, error) {
+	if err := h.service.CreateWebhook(w); err != nil {
+		logging.NewLogger().Sugar().
+			With("url", w.Url).
+			With("error", err).
+			Warn("error creating webhook")
+		return nil, toStatus(err)
+	}
+	return w, nil
+}
+
+func (h *userHandler) ListWebhooks(ctx context.Context, _ *empty.Empty) (*pb.ListWebhooksResponse, error) {
+	webhooks, err := h.service.ListWebhooks()
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("error", err).
+			Warn("error listing webhooks")
+		return nil, toStatus(err)
+	}
+	return &pb.ListWebhooksResponse{Webhooks: webhooks}, nil
+}
+
+func (h *userHandler) DeleteWebhook(ctx context.Context, id *pb.WebhookId) (*empty.Empty, error) {
+	if err := h.service.DeleteWebhook(id.Id); err != nil {
+		logging.NewLogger().Sugar().
+			With("webhookID", id.Id).
+			With("error", err).
+			Warn("error deleting webhook")
+		return nil, toStatus(err)
+	}
+	return &empty.Empty{}, nil
+}
+
+func (h *userHandler) ListDeadLetters(ctx context.Context, req *pb.ListDeadLettersRequest) (*pb.ListDeadLettersResponse, error) {
+	results, err := h.service.ListDeadLetters(req.WebhookId, userservice.Page{
+		Size:  int(req.PageSize),
+		Token: req.PageToken,
+	})
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("request", req).
+			With("error", err).
+			Warn("error listing dead letters")
+		return nil, toStatus(err)
+	}
+	return &pb.ListDeadLettersResponse{
+		Deliveries:    results.Deliveries,
+		NextPageToken: results.NextToken,
+	}, nil
+}
+
+func (h *userHandler) Replay(ctx context.Context, req *pb.ReplayRequest) (*empty.Empty, error) {
+	if err := h.service.Replay(req.Id); err != nil {
+		logging.NewLogger().Sugar().
+			With("deliveryID", req.Id).
+			With("error", err).
+			Warn("error replaying dead letter")
+		return nil, toStatus(err)
+	}
+	return &empty.Empty{}, nil
+}
@@ -0,0 +1,105 @@
+// Package userio reads and writes the files of user imports and exports, and defines the errors reported
+// by the userservice. It does not depend on any Store, so that clients such as userctl can check files locally.
+package userio
+
+import "strings"
+
+// Kind classifies an Error by how it should be reported to clients
+type Kind int
+
+const (
+	// Internal errors are unexpected failures, whose detail must not be returned to clients
+	Internal Kind = iota
+	// InvalidArgument errors are caused by a malformed request
+	InvalidArgument
+	// NotFound errors are returned when a requested user does not exist
+	NotFound
+	// AlreadyExists errors are returned when a user conflicts with an existing user
+	AlreadyExists
+	// Unauthenticated errors are returned when credentials do not match
+	Unauthenticated
+	// Unavailable errors are returned when the user store cannot currently be reached, and may be retried
+	Unavailable
+	// FailedPrecondition errors are returned when a user has changed since the client read it
+	FailedPrecondition
+)
+
+// Error is an error returned by the userservice, carrying a stable, machine readable Reason
+type Error struct {
+	Kind Kind
+	// Reason identifies the cause of the error, e.g. DUPLICATE_USER
+	Reason string
+	// Message describes the error and is safe to return to clients
+	Message string
+	// Err is the underlying cause, for logging only
+	Err error
+	// Violations lists the invalid fields of an INVALID_USER error
+	Violations []FieldViolation
+}
+
+// FieldViolation describes why a field of a request is invalid
+type FieldViolation struct {
+	// Field is the name of the field, e.g. email
+	Field       string
+	Description string
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying cause of the error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an Error with the same Reason, so that
+// errors.Is matches sentinel errors regardless of their cause
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Reason == e.Reason
+}
+
+// WithCause returns a copy of the error with the underlying cause attached
+func (e *Error) WithCause(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+// InvalidArgumentError returns an InvalidArgument error with the reason and message
+func InvalidArgumentError(reason, message string) *Error {
+	return &Error{Kind: InvalidArgument, Reason: reason, Message: message}
+}
+
+// InvalidUserError returns an INVALID_USER error listing the invalid fields of a user
+func InvalidUserError(violations []FieldViolation) *Error {
+	descriptions := make([]string, len(violations))
+	for i, v := range violations {
+		descriptions[i] = v.Description
+	}
+	return &Error{
+		Kind:       InvalidArgument,
+		Reason:     "INVALID_USER",
+		Message:    strings.Join(descriptions, "; "),
+		Violations: violations,
+	}
+}
+
+// RowError is returned by an ImportReader for a row which cannot be read
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the reason that the row cannot be read
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
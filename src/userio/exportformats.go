@@ -0,0 +1,72 @@
+package userio
+
+import (
+	"encoding/csv"
+	"io"
+
+	"github.com/beldin0/users/src/user"
+	"github.com/golang/protobuf/jsonpb"
+)
+
+// ExportWriter writes exported users to a file
+type ExportWriter interface {
+	Write(u *user.User) error
+	// Flush writes any buffered users, and must be called after the last user
+	Flush() error
+}
+
+// csvWriter writes users as CSV with a header row
+type csvWriter struct {
+	w      *csv.Writer
+	fields []string
+	record []string
+}
+
+// NewCSVWriter returns an ExportWriter writing the fields listed by the read mask of an export as CSV,
+// or every field if it is empty, starting with a header row naming them which NewCSVReader accepts
+func NewCSVWriter(w io.Writer, mask []string) (ExportWriter, error) {
+	fields, err := ExportFields(mask)
+	if err != nil {
+		return nil, err
+	}
+	c := &csvWriter{w: csv.NewWriter(w), fields: fields, record: make([]string, len(fields))}
+	if err := c.w.Write(fields); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *csvWriter) Write(u *user.User) error {
+	for i, f := range c.fields {
+		c.record[i] = exportFieldsByName[f].text(u)
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonlWriter writes a user to each line as JSON
+type jsonlWriter struct {
+	w io.Writer
+	m jsonpb.Marshaler
+}
+
+// NewJSONLWriter returns an ExportWriter writing a user to each line as JSON, in the form returned by the API
+func NewJSONLWriter(w io.Writer) ExportWriter {
+	return &jsonlWriter{w: w}
+}
+
+func (j *jsonlWriter) Write(u *user.User) error {
+	if err := j.m.Marshal(j.w, u); err != nil {
+		return err
+	}
+	_, err := j.w.Write([]byte("\n"))
+	return err
+}
+
+func (j *jsonlWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,101 @@
+package userio
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/beldin0/users/src/user"
+	"github.com/golang/protobuf/ptypes"
+)
+
+// exportField is a field of a user which can be exported
+type exportField struct {
+	// copy sets the field of dst to its value in src
+	copy func(dst, src *user.User)
+	// text returns the value of the field as it is written to CSV
+	text func(u *user.User) string
+}
+
+// exportFields are the fields which can be exported, in the order they are exported by default
+var exportFields = []string{"id", "firstName", "lastName", "nickname", "email", "country", "etag", "deleteTime"}
+
+// exportFieldsByName maps the exported fields, and their snake_case aliases, to how they are exported
+var exportFieldsByName = map[string]exportField{
+	"id": {
+		copy: func(dst, src *user.User) { dst.Id = src.Id },
+		text: func(u *user.User) string { return strconv.Itoa(int(u.Id)) },
+	},
+	"firstName": {
+		copy: func(dst, src *user.User) { dst.FirstName = src.FirstName },
+		text: func(u *user.User) string { return u.FirstName },
+	},
+	"lastName": {
+		copy: func(dst, src *user.User) { dst.LastName = src.LastName },
+		text: func(u *user.User) string { return u.LastName },
+	},
+	"nickname": {
+		copy: func(dst, src *user.User) { dst.Nickname = src.Nickname },
+		text: func(u *user.User) string { return u.Nickname },
+	},
+	"email": {
+		copy: func(dst, src *user.User) { dst.Email = src.Email },
+		text: func(u *user.User) string { return u.Email },
+	},
+	"country": {
+		copy: func(dst, src *user.User) { dst.Country = src.Country },
+		text: func(u *user.User) string { return u.Country },
+	},
+	"etag": {
+		copy: func(dst, src *user.User) { dst.Etag = src.Etag },
+		text: func(u *user.User) string { return u.Etag },
+	},
+	"deleteTime": {
+		copy: func(dst, src *user.User) { dst.DeleteTime = src.DeleteTime },
+		text: func(u *user.User) string {
+			if u.DeleteTime == nil {
+				return ""
+			}
+			t, _ := ptypes.Timestamp(u.DeleteTime)
+			return t.Format(time.RFC3339)
+		},
+	},
+}
+
+// exportAliases maps the snake_case names of the exported fields to their names
+var exportAliases = map[string]string{
+	"first_name":  "firstName",
+	"last_name":   "lastName",
+	"delete_time": "deleteTime",
+}
+
+// ExportFields returns the names of the fields listed by the read mask of an export, without duplicates,
+// or every field if it is empty. Unknown fields are rejected with an INVALID_READ_MASK error.
+func ExportFields(mask []string) ([]string, error) {
+	if len(mask) == 0 {
+		return append([]string{}, exportFields...), nil
+	}
+	seen := map[string]bool{}
+	fields := []string{}
+	for _, f := range mask {
+		if alias, ok := exportAliases[f]; ok {
+			f = alias
+		}
+		if _, ok := exportFieldsByName[f]; !ok {
+			return nil, InvalidArgumentError("INVALID_READ_MASK", "field "+f+" cannot be exported")
+		}
+		if !seen[f] {
+			seen[f] = true
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// Project returns a copy of the user holding only the fields, as returned by ExportFields
+func Project(u *user.User, fields []string) *user.User {
+	projected := &user.User{}
+	for _, f := range fields {
+		exportFieldsByName[f].copy(projected, u)
+	}
+	return projected
+}
@@ -0,0 +1,119 @@
+package userio
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/beldin0/users/src/user"
+	"github.com/golang/protobuf/jsonpb"
+)
+
+// ImportReader reads the users to import
+type ImportReader interface {
+	// Read returns the next user to import and the line it was read from, or io.EOF after the last user.
+	// A *RowError is returned for a row which cannot be read, and reading continues after it.
+	Read() (*user.User, int, error)
+}
+
+// csvColumns maps the header of a CSV column to the field of the user it holds
+var csvColumns = map[string]func(u *user.User, value string){
+	"firstname":  func(u *user.User, v string) { u.FirstName = v },
+	"first_name": func(u *user.User, v string) { u.FirstName = v },
+	"lastname":   func(u *user.User, v string) { u.LastName = v },
+	"last_name":  func(u *user.User, v string) { u.LastName = v },
+	"nickname":   func(u *user.User, v string) { u.Nickname = v },
+	"password":   func(u *user.User, v string) { u.Password = v },
+	"email":      func(u *user.User, v string) { u.Email = v },
+	"country":    func(u *user.User, v string) { u.Country = v },
+	// the other columns written by NewCSVWriter are ignored, as they are set when users are added
+	"id":          func(*user.User, string) {},
+	"etag":        func(*user.User, string) {},
+	"deletetime":  func(*user.User, string) {},
+	"delete_time": func(*user.User, string) {},
+}
+
+// csvReader reads users from CSV with a header row
+type csvReader struct {
+	r       *csv.Reader
+	columns []func(u *user.User, value string)
+	line    int
+}
+
+// NewCSVReader returns an ImportReader reading users from CSV, whose header row names the field of
+// each column, e.g. firstName or first_name. Line numbers count records, so assume that no field
+// spans lines.
+func NewCSVReader(r io.Reader) (ImportReader, error) {
+	c := &csvReader{r: csv.NewReader(r), line: 1}
+	c.r.ReuseRecord = true
+	header, err := c.r.Read()
+	if err == io.EOF {
+		return nil, InvalidArgumentError("INVALID_CSV", "CSV must start with a header row")
+	}
+	if err != nil {
+		return nil, InvalidArgumentError("INVALID_CSV", "invalid CSV header: "+err.Error())
+	}
+	for _, name := range header {
+		set, ok := csvColumns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, InvalidArgumentError("INVALID_CSV", "unknown CSV column "+name)
+		}
+		c.columns = append(c.columns, set)
+	}
+	return c, nil
+}
+
+func (c *csvReader) Read() (*user.User, int, error) {
+	record, err := c.r.Read()
+	if err == io.EOF {
+		return nil, 0, io.EOF
+	}
+	c.line++
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, c.line, &RowError{Line: c.line, Err: InvalidArgumentError("INVALID_ROW", parseErr.Err.Error())}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	u := &user.User{}
+	for i, value := range record {
+		c.columns[i](u, value)
+	}
+	return u, c.line, nil
+}
+
+// jsonlReader reads a user from each line of JSON
+type jsonlReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// NewJSONLReader returns an ImportReader reading a user from each line of JSON, in the form returned by the API.
+// Blank lines are skipped.
+func NewJSONLReader(r io.Reader) ImportReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64<<10), 1<<20)
+	return &jsonlReader{s: s}
+}
+
+func (j *jsonlReader) Read() (*user.User, int, error) {
+	for j.s.Scan() {
+		j.line++
+		line := strings.TrimSpace(j.s.Text())
+		if line == "" {
+			continue
+		}
+		u := &user.User{}
+		if err := jsonpb.UnmarshalString(line, u); err != nil {
+			return nil, j.line, &RowError{Line: j.line, Err: InvalidArgumentError("INVALID_ROW", "invalid JSON user: "+err.Error())}
+		}
+		return u, j.line, nil
+	}
+	if err := j.s.Err(); err != nil {
+		return nil, 0, err
+	}
+	return nil, 0, io.EOF
+}
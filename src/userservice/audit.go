@@ -0,0 +1,178 @@
+package userservice
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/beldin0/users/src/user"
+	"github.com/golang/protobuf/ptypes"
+)
+
+// Audit identifies who made a change, and is recorded with every mutation in the audit log
+type Audit struct {
+	Actor     string
+	RequestID string
+}
+
+// The operations recorded in the audit log
+const (
+	OpCreate  = "CREATE"
+	OpModify  = "MODIFY"
+	OpUpdate  = "UPDATE"
+	OpDelete  = "DELETE"
+	OpRestore = "RESTORE"
+	OpPurge   = "PURGE"
+)
+
+// redacted replaces passwords in the audit log, which only records that they changed
+const redacted = "[REDACTED]"
+
+// AuditResults is a single page of the audit events of a user
+type AuditResults struct {
+	Events []*user.AuditEvent
+	// NextToken requests the following page, and is empty on the last page
+	NextToken string
+}
+
+// auditedFields are the fields of a user compared for the audit log, in the order they are reported
+var auditedFields = []struct {
+	name  string
+	value func(*user.User) string
+}{
+	{"firstName", func(u *user.User) string { return u.FirstName }},
+	{"lastName", func(u *user.User) string { return u.LastName }},
+	{"nickname", func(u *user.User) string { return u.Nickname }},
+	{"email", func(u *user.User) string { return u.Email }},
+	{"country", func(u *user.User) string { return u.Country }},
+	{"deleteTime", func(u *user.User) string {
+		if u.DeleteTime == nil {
+			return ""
+		}
+		return ptypes.TimestampString(u.DeleteTime)
+	}},
+}
+
+// change is a FieldChange as it is stored in the audit log
+type change struct {
+	Field  string `json:"field"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// diff returns the fields that differ between the user before and after a change, where a nil user has no fields.
+// A changed password is recorded without its value.
+func diff(before, after *user.User, passwordChanged bool) []change {
+	changes := []change{}
+	for _, field := range auditedFields {
+		var b, a string
+		if before != nil {
+			b = field.value(before)
+		}
+		if after != nil {
+			a = field.value(after)
+		}
+		if a != b {
+			changes = append(changes, change{Field: field.name, Before: b, After: a})
+		}
+	}
+	if passwordChanged {
+		c := change{Field: "password", After: redacted}
+		if before != nil {
+			c.Before = redacted
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// auditRecord is a row of the user_audit table
+type auditRecord struct {
+	ID        int64     `db:"id"`
+	UserID    int32     `db:"user_id"`
+	Actor     string    `db:"actor"`
+	Operation string    `db:"operation"`
+	Changes   string    `db:"changes"`
+	RequestID string    `db:"request_id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// newAuditRecord returns the audit record of a change to the user made by a
+func newAuditRecord(a Audit, op string, userID int32, changes []change) (auditRecord, error) {
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return auditRecord{}, err
+	}
+	return auditRecord{
+		UserID:    userID,
+		Actor:     a.Actor,
+		Operation: op,
+		Changes:   string(encoded),
+		RequestID: a.RequestID,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// toEvent returns the audit event held by the record
+func (r auditRecord) toEvent() (*user.AuditEvent, error) {
+	var changes []change
+	if err := json.Unmarshal([]byte(r.Changes), &changes); err != nil {
+		return nil, err
+	}
+	ts, err := ptypes.TimestampProto(r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	event := &user.AuditEvent{
+		Id:        r.ID,
+		UserId:    r.UserID,
+		Actor:     r.Actor,
+		Operation: r.Operation,
+		RequestId: r.RequestID,
+		Time:      ts,
+	}
+	for _, c := range changes {
+		event.Changes = append(event.Changes, &user.FieldChange{Field: c.Field, Before: c.Before, After: c.After})
+	}
+	return event, nil
+}
+
+// auditResults returns a page of at most size events from the records, which may hold one more
+// record than the page to show that there is a following page
+func auditResults(records []auditRecord, size int) (*AuditResults, error) {
+	results := &AuditResults{Events: []*user.AuditEvent{}}
+	for i, r := range records {
+		if i == size {
+			results.NextToken = idToken(records[size-1].ID)
+			break
+		}
+		event, err := r.toEvent()
+		if err != nil {
+			return nil, err
+		}
+		results.Events = append(results.Events, event)
+	}
+	return results, nil
+}
+
+// idToken encodes the id of an audit or outbox event as an opaque token, for page and resume tokens
+func idToken(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// tokenID returns the id encoded by idToken, 0 for an empty token
+func tokenID(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrInvalidPageToken
+	}
+	id, err := strconv.ParseInt(string(decoded), 10, 64)
+	if err != nil || id < 1 {
+		return 0, ErrInvalidPageToken
+	}
+	return id, nil
+}
@@ -0,0 +1,28 @@
+package userservice
+
+import "sync"
+
+// broadcaster wakes every watcher when users change
+type broadcaster struct {
+	mu      sync.Mutex
+	changed chan struct{}
+}
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{changed: make(chan struct{})}
+}
+
+// wait returns a channel which is closed at the next change
+func (b *broadcaster) wait() <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.changed
+}
+
+// notify wakes the current watchers
+func (b *broadcaster) notify() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
@@ -0,0 +1,148 @@
+package userservice
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/beldin0/users/src/logging"
+	"github.com/golang/protobuf/jsonpb"
+)
+
+const (
+	// dispatchBatch is the number of due deliveries attempted at a time
+	dispatchBatch = 100
+	// maxBackoff caps the time between attempts to make a delivery
+	maxBackoff = time.Hour
+	// deliveryTimeout limits how long a webhook may take to respond
+	deliveryTimeout = 10 * time.Second
+)
+
+// Dispatcher delivers events to webhooks as JSON, signed with the secret of each webhook (see Sign).
+// A delivery succeeds when the webhook responds with a 2xx status; failed deliveries are retried with
+// exponential backoff, and become dead letters after the maximum number of attempts.
+// Only one Dispatcher should run against a Store.
+type Dispatcher struct {
+	store       Store
+	client      *http.Client
+	interval    time.Duration
+	backoff     time.Duration
+	maxAttempts int
+	marshaler   jsonpb.Marshaler
+}
+
+// NewDispatcher returns a Dispatcher checking for due deliveries every interval. A failed delivery is retried
+// after backoff, which doubles after every further failure up to an hour, until it has been attempted maxAttempts times.
+func NewDispatcher(store Store, interval, backoff time.Duration, maxAttempts int) *Dispatcher {
+	return &Dispatcher{
+		store:       store,
+		client:      &http.Client{Timeout: deliveryTimeout},
+		interval:    interval,
+		backoff:     backoff,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Run delivers events until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		for {
+			attempted, err := d.Flush(ctx)
+			if err != nil {
+				logging.NewLogger().Sugar().With("error", err).Warn("error delivering webhooks")
+			}
+			// keep going while there is a backlog, otherwise wait for new deliveries
+			if err != nil || attempted < dispatchBatch {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush attempts the deliveries which are due, returning how many were attempted
+func (d *Dispatcher) Flush(ctx context.Context) (int, error) {
+	deliveries, err := d.store.DueDeliveries(time.Now(), dispatchBatch)
+	if err != nil {
+		return 0, err
+	}
+	for i, delivery := range deliveries {
+		err := d.deliver(ctx, delivery)
+		if ctx.Err() != nil {
+			// shutting down, so the delivery is attempted again without counting as a failure
+			return i, nil
+		}
+		if err != nil {
+			err = d.failed(delivery, err)
+		} else {
+			err = d.store.RecordAttempt(delivery.ID, DeliveryDelivered, delivery.Attempts, "", time.Now())
+		}
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// failed schedules the next attempt of a delivery, or makes it a dead letter if it has no attempts left
+func (d *Dispatcher) failed(delivery Delivery, cause error) error {
+	attempts := delivery.Attempts + 1
+	logging.NewLogger().Sugar().
+		With("delivery", delivery.ID).
+		With("webhookID", delivery.WebhookID).
+		With("attempts", attempts).
+		With("error", cause).
+		Warn("error delivering webhook")
+	if attempts >= d.maxAttempts {
+		return d.store.RecordAttempt(delivery.ID, DeliveryDead, attempts, cause.Error(), time.Now())
+	}
+	backoff := d.backoff
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return d.store.RecordAttempt(delivery.ID, DeliveryPending, attempts, cause.Error(), time.Now().Add(backoff))
+}
+
+// deliver POSTs the event to the webhook
+func (d *Dispatcher) deliver(ctx context.Context, delivery Delivery) error {
+	body, err := d.marshaler.MarshalToString(delivery.Event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader([]byte(body)))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", strconv.Itoa(int(delivery.WebhookID)))
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Webhook-Event", EventType(delivery.Event))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(delivery.Secret, timestamp, []byte(body)))
+	resp, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// drain the body so that the connection can be reused
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -1,8 +1,105 @@
 package userservice
 
-import "errors"
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/beldin0/users/src/userio"
+	"github.com/lib/pq"
+)
+
+// Kind classifies an Error by how it should be reported to clients
+type Kind = userio.Kind
+
+// The kinds of Error, declared by userio
+const (
+	Internal           = userio.Internal
+	InvalidArgument    = userio.InvalidArgument
+	NotFound           = userio.NotFound
+	AlreadyExists      = userio.AlreadyExists
+	Unauthenticated    = userio.Unauthenticated
+	Unavailable        = userio.Unavailable
+	FailedPrecondition = userio.FailedPrecondition
+)
+
+// Error is an error returned by the Service, carrying a stable, machine readable Reason
+type Error = userio.Error
+
+// FieldViolation describes why a field of a request is invalid
+type FieldViolation = userio.FieldViolation
 
 var (
 	// ErrDuplicate is the error returned when an Add request is sent with an email that is already in use
-	ErrDuplicate = errors.New("key already exists")
+	ErrDuplicate = &Error{Kind: AlreadyExists, Reason: "DUPLICATE_USER", Message: "email or nickname already in use"}
+	// ErrNotFound is the error returned when the requested user does not exist
+	ErrNotFound = &Error{Kind: NotFound, Reason: "USER_NOT_FOUND", Message: "user not found"}
+	// ErrNotDeleted is the error returned when restoring a user that does not exist or has not been deleted
+	ErrNotDeleted = &Error{Kind: NotFound, Reason: "DELETED_USER_NOT_FOUND", Message: "no deleted user with the id"}
+	// ErrInvalidRetention is the error returned when purging users with a retention window of less than a day
+	ErrInvalidRetention = &Error{Kind: InvalidArgument, Reason: "INVALID_RETENTION", Message: "retention must be at least one day"}
+	// ErrWebhookNotFound is the error returned when the requested webhook does not exist
+	ErrWebhookNotFound = &Error{Kind: NotFound, Reason: "WEBHOOK_NOT_FOUND", Message: "webhook not found"}
+	// ErrDeadLetterNotFound is the error returned when replaying a delivery that does not exist or has not failed
+	ErrDeadLetterNotFound = &Error{Kind: NotFound, Reason: "DEAD_LETTER_NOT_FOUND", Message: "no dead letter with the id"}
+	// ErrEtagRequired is the error returned when a user is modified or deleted without its etag
+	ErrEtagRequired = &Error{Kind: InvalidArgument, Reason: "ETAG_REQUIRED", Message: "the etag of the user must be provided"}
+	// ErrEtagMismatch is the error returned when a user has been modified since its etag was read
+	ErrEtagMismatch = &Error{Kind: FailedPrecondition, Reason: "ETAG_MISMATCH", Message: "user has been modified since it was read"}
+	// ErrInvalidCredentials is the error returned when a password does not match the stored password
+	ErrInvalidCredentials = &Error{Kind: Unauthenticated, Reason: "INVALID_CREDENTIALS", Message: "invalid credentials"}
+	// ErrTooManyIDs is the error returned when a batch requests more than MaxBatchSize users
+	ErrTooManyIDs = &Error{Kind: InvalidArgument, Reason: "TOO_MANY_IDS", Message: fmt.Sprintf("at most %d users can be requested at once", MaxBatchSize)}
+	// ErrInvalidPageSize is the error returned when a search requests a negative page size
+	ErrInvalidPageSize = &Error{Kind: InvalidArgument, Reason: "INVALID_PAGE_SIZE", Message: "page size must not be negative"}
+	// ErrInvalidPageToken is the error returned when a page token is malformed or was issued for a different ordering
+	ErrInvalidPageToken = &Error{Kind: InvalidArgument, Reason: "INVALID_PAGE_TOKEN", Message: "invalid page token"}
+	// ErrInvalidResumeToken is the error returned when a Watch resume token is malformed
+	ErrInvalidResumeToken = &Error{Kind: InvalidArgument, Reason: "INVALID_RESUME_TOKEN", Message: "invalid resume token"}
+	// ErrInvalidOrderBy is the error returned when a search is ordered by an unsupported field
+	ErrInvalidOrderBy = &Error{Kind: InvalidArgument, Reason: "INVALID_ORDER_BY", Message: "order by must be one of id, last_name, nickname or email, or relevance for fuzzy searches"}
+	// ErrUnavailable is the error returned when the database cannot be reached
+	ErrUnavailable = &Error{Kind: Unavailable, Reason: "STORE_UNAVAILABLE", Message: "user store is unavailable"}
+	// ErrInternal is the error returned for unexpected failures
+	ErrInternal = &Error{Kind: Internal, Reason: "INTERNAL", Message: "internal error"}
 )
+
+// classify converts database errors into an Error, so that the driver's error text is
+// only ever logged. Errors which are already classified are returned unchanged.
+func classify(err error, d dialect) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	if strings.Contains(err.Error(), d.duplicate) {
+		return ErrDuplicate.WithCause(err)
+	}
+	if unavailable(err) {
+		return ErrUnavailable.WithCause(err)
+	}
+	return ErrInternal.WithCause(err)
+}
+
+// unavailable reports whether the error means that the database could not be reached
+func unavailable(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Class 08 is connection exceptions, 57P0x is the server shutting down or starting up
+		return pqErr.Code.Class() == "08" || strings.HasPrefix(string(pqErr.Code), "57P0")
+	}
+	// SQLite reports contention with a single writer as a locked database
+	return strings.Contains(err.Error(), "database is locked")
+}
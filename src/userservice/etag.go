@@ -0,0 +1,22 @@
+package userservice
+
+import (
+	"strconv"
+	"strings"
+)
+
+// etag returns the etag of a user at version
+func etag(version int64) string {
+	return strconv.FormatInt(version, 10)
+}
+
+// parseEtag returns the version of a user from its etag, accepting the quoted and weak forms
+// used in HTTP headers. A malformed etag cannot match any version, so is reported as ErrEtagMismatch.
+func parseEtag(tag string) (int64, error) {
+	tag = strings.Trim(strings.TrimPrefix(tag, "W/"), `"`)
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil || version < 1 {
+		return 0, ErrEtagMismatch
+	}
+	return version, nil
+}
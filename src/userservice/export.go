@@ -0,0 +1,31 @@
+package userservice
+
+import (
+	"github.com/beldin0/users/src/logging"
+	"github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userio"
+)
+
+// exportBatch is the number of users fetched from the export cursor at a time
+const exportBatch = 1000
+
+// Export calls f with each user matching the SearchOptions in order of id, holding only the fields listed
+// by the read mask, or every field if it is empty. A nil SearchOptions exports every live user.
+// Users are read from the store as they are exported, so that memory use does not grow with their number.
+func (s *Service) Export(o *SearchOptions, mask []string, f func(*user.User) error) error {
+	fields, err := userio.ExportFields(mask)
+	if err != nil {
+		return err
+	}
+	exported := 0
+	err = s.store.Export(o, func(u *user.User) error {
+		exported++
+		return f(userio.Project(u, fields))
+	})
+	logging.NewLogger().Sugar().
+		With("function", "export").
+		With("search", o.values()).
+		With("exported", exported).
+		Info("users exported")
+	return err
+}
@@ -0,0 +1,503 @@
+package userservice
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/beldin0/users/src/country"
+	"github.com/beldin0/users/src/userio"
+)
+
+// Filter is a parsed filter expression in the style of AIP-160, which restricts the users matched by a search,
+// e.g. country = "GB" AND (nickname:"ali*" OR email:"*@acme.com")
+//
+// Restrictions compare a field with a value using =, != or : (which is the same as =), or <, <=, > and >= for
+// id and deleteTime, or list the values of a field with IN ("a", "b"). Text values can use * as a wildcard, and
+// are matched ignoring case. Restrictions are combined with AND, OR (which binds more tightly than AND) and NOT,
+// and grouped with parentheses; restrictions separated only by spaces must all match.
+type Filter struct {
+	expr filterExpr
+}
+
+// filterExpr is a node of a parsed filter
+type filterExpr interface {
+	// sql compiles the expression to a condition using ? placeholders, appending its arguments to args
+	sql(args []interface{}) (string, []interface{})
+	// eval reports whether a user matches the expression, using the same rules as the compiled condition.
+	// column returns the user's value for a database column.
+	eval(column func(string) string) bool
+}
+
+// filterKind is the type of the values of a field
+type filterKind int
+
+const (
+	filterText filterKind = iota
+	filterID
+	filterTime
+)
+
+// filterField is a field of a user which can be filtered on
+type filterField struct {
+	name   string
+	column string
+	kind   filterKind
+	// normalize converts a text value to its stored form
+	normalize func(string) string
+}
+
+// filterFields are the fields which can be filtered on, by the names of the fields of a user in either case
+var filterFields = map[string]filterField{}
+
+func init() {
+	for _, f := range []filterField{
+		{name: "id", column: "id", kind: filterID},
+		{name: "firstName", column: "first_name_lower", normalize: strings.ToLower},
+		{name: "lastName", column: "last_name_lower", normalize: strings.ToLower},
+		{name: "nickname", column: "nickname_lower", normalize: strings.ToLower},
+		{name: "email", column: "email", normalize: strings.ToLower},
+		{name: "country", column: "country", normalize: country.Canonical},
+		{name: "deleteTime", column: "deleted_at", kind: filterTime},
+	} {
+		filterFields[f.name] = f
+		filterFields[snakeCase(f.name)] = f
+	}
+}
+
+func snakeCase(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// ParseFilter parses a filter expression, returning an INVALID_FILTER error describing where it is invalid.
+// An empty filter matches every user.
+func ParseFilter(filter string) (*Filter, error) {
+	if strings.TrimSpace(filter) == "" {
+		return &Filter{}, nil
+	}
+	tokens, err := lexFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	expr, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, filterError(t, "unexpected "+t.describe())
+	}
+	return &Filter{expr: expr}, nil
+}
+
+func filterError(t token, message string) error {
+	return userio.InvalidArgumentError("INVALID_FILTER", fmt.Sprintf("invalid filter at position %d: %s", t.pos+1, message))
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	// tokenText is a bare word, which is a field name, keyword or unquoted value
+	tokenText
+	tokenString
+	tokenComparator
+	tokenLeftParen
+	tokenRightParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// pos is the offset of the token in the filter, in characters
+	pos int
+}
+
+func (t token) describe() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of filter"
+	case tokenString:
+		return strconv.Quote(t.text)
+	}
+	return "'" + t.text + "'"
+}
+
+// keyword reports whether the token is the keyword, which must be in upper case
+func (t token) keyword(k string) bool {
+	return t.kind == tokenText && t.text == k
+}
+
+// lexFilter splits a filter into tokens, ending with a tokenEOF
+func lexFilter(filter string) ([]token, error) {
+	r := []rune(filter)
+	tokens := []token{}
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLeftParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRightParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: i})
+			i++
+		case c == '=' || c == ':':
+			tokens = append(tokens, token{kind: tokenComparator, text: string(c), pos: i})
+			i++
+		case c == '<' || c == '>' || c == '!':
+			text := string(c)
+			if i+1 < len(r) && r[i+1] == '=' {
+				text += "="
+			}
+			if text == "!" {
+				return nil, filterError(token{pos: i}, "expected != but found '!'")
+			}
+			tokens = append(tokens, token{kind: tokenComparator, text: text, pos: i})
+			i += len(text)
+		case c == '"' || c == '\'':
+			start := i
+			var b strings.Builder
+			for i++; ; i++ {
+				if i == len(r) {
+					return nil, filterError(token{pos: start}, "unterminated string")
+				}
+				if r[i] == '\\' && i+1 < len(r) {
+					i++
+				} else if r[i] == c {
+					break
+				}
+				b.WriteRune(r[i])
+			}
+			tokens = append(tokens, token{kind: tokenString, text: b.String(), pos: start})
+			i++
+		default:
+			start := i
+			for i < len(r) && !unicode.IsSpace(r[i]) && !strings.ContainsRune(`()=:<>!,"'`, r[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenText, text: string(r[start:i]), pos: start})
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(r)}), nil
+}
+
+// filterParser parses the grammar of AIP-160:
+//
+//	expression  = sequence {"AND" sequence}
+//	sequence    = factor {factor}
+//	factor      = term {"OR" term}
+//	term        = ["NOT"] simple
+//	simple      = restriction | "(" expression ")"
+//	restriction = field comparator value | field "IN" "(" value {"," value} ")"
+type filterParser struct {
+	tokens []token
+	i      int
+}
+
+func (p *filterParser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *filterParser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *filterParser) expression() (filterExpr, error) {
+	left, err := p.sequence()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("AND") {
+		p.next()
+		right, err := p.sequence()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) sequence() (filterExpr, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenLeftParen && (t.kind != tokenText || t.keyword("AND") || t.keyword("OR")) {
+			return left, nil
+		}
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{op: "AND", left: left, right: right}
+	}
+}
+
+func (p *filterParser) factor() (filterExpr, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("OR") {
+		p.next()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) term() (filterExpr, error) {
+	if !p.peek().keyword("NOT") {
+		return p.simple()
+	}
+	p.next()
+	expr, err := p.simple()
+	if err != nil {
+		return nil, err
+	}
+	return &notExpr{expr: expr}, nil
+}
+
+func (p *filterParser) simple() (filterExpr, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokenLeftParen:
+		expr, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if end := p.next(); end.kind != tokenRightParen {
+			return nil, filterError(end, "expected ')' but found "+end.describe())
+		}
+		return expr, nil
+	case t.kind != tokenText || t.keyword("AND") || t.keyword("OR") || t.keyword("NOT"):
+		return nil, filterError(t, "expected a field but found "+t.describe())
+	}
+	field, ok := filterFields[t.text]
+	if !ok {
+		return nil, filterError(t, "unknown field '"+t.text+"', expected one of id, firstName, lastName, nickname, email, country or deleteTime")
+	}
+	if p.peek().keyword("IN") {
+		p.next()
+		return p.in(field)
+	}
+	op := p.next()
+	if op.kind != tokenComparator {
+		return nil, filterError(op, "expected a comparison of "+field.name+" but found "+op.describe())
+	}
+	if field.kind == filterText && op.text != "=" && op.text != "!=" && op.text != ":" {
+		return nil, filterError(op, field.name+" can only be compared with =, != or :")
+	}
+	v, err := p.value(field)
+	if err != nil {
+		return nil, err
+	}
+	switch op.text {
+	case ":":
+		v.op = "="
+	case "!=":
+		v.op = "="
+		return &notExpr{expr: v}, nil
+	default:
+		v.op = op.text
+	}
+	return v, nil
+}
+
+// in parses the values of an IN restriction
+func (p *filterParser) in(field filterField) (filterExpr, error) {
+	if t := p.next(); t.kind != tokenLeftParen {
+		return nil, filterError(t, "expected '(' after IN but found "+t.describe())
+	}
+	if field.kind == filterTime {
+		return nil, filterError(p.peek(), field.name+" cannot be used with IN")
+	}
+	in := &inExpr{field: field}
+	for {
+		v, err := p.value(field)
+		if err != nil {
+			return nil, err
+		}
+		if v.like != nil {
+			return nil, filterError(p.tokens[p.i-1], "wildcards cannot be used with IN")
+		}
+		in.values = append(in.values, v.value)
+		t := p.next()
+		if t.kind == tokenRightParen {
+			return in, nil
+		}
+		if t.kind != tokenComma {
+			return nil, filterError(t, "expected ',' or ')' but found "+t.describe())
+		}
+	}
+}
+
+// value parses a value of the field, returning it as a comparison with the field
+func (p *filterParser) value(field filterField) (*comparison, error) {
+	t := p.next()
+	if t.kind != tokenText && t.kind != tokenString {
+		return nil, filterError(t, "expected a value for "+field.name+" but found "+t.describe())
+	}
+	c := &comparison{field: field}
+	switch field.kind {
+	case filterID:
+		id, err := strconv.ParseInt(t.text, 10, 32)
+		if err != nil {
+			return nil, filterError(t, field.name+" must be an integer")
+		}
+		c.value = id
+	case filterTime:
+		ts, err := time.Parse(time.RFC3339Nano, t.text)
+		if err != nil {
+			return nil, filterError(t, field.name+` must be an RFC 3339 timestamp in quotes, e.g. "2020-01-02T15:04:05Z"`)
+		}
+		c.value = ts.UTC()
+	default:
+		value := field.normalize(t.text)
+		c.value = value
+		if strings.Contains(value, "*") {
+			parts := strings.Split(value, "*")
+			like := make([]string, len(parts))
+			for i, part := range parts {
+				like[i] = regexp.QuoteMeta(part)
+			}
+			c.like = regexp.MustCompile("^" + strings.Join(like, ".*") + "$")
+		}
+	}
+	return c, nil
+}
+
+// logicalExpr requires both (AND) or either (OR) of its expressions
+type logicalExpr struct {
+	op          string
+	left, right filterExpr
+}
+
+func (e *logicalExpr) sql(args []interface{}) (string, []interface{}) {
+	left, args := e.left.sql(args)
+	right, args := e.right.sql(args)
+	return "(" + left + " " + e.op + " " + right + ")", args
+}
+
+func (e *logicalExpr) eval(column func(string) string) bool {
+	if e.op == "AND" {
+		return e.left.eval(column) && e.right.eval(column)
+	}
+	return e.left.eval(column) || e.right.eval(column)
+}
+
+type notExpr struct {
+	expr filterExpr
+}
+
+func (e *notExpr) sql(args []interface{}) (string, []interface{}) {
+	condition, args := e.expr.sql(args)
+	return "NOT " + condition, args
+}
+
+func (e *notExpr) eval(column func(string) string) bool {
+	return !e.expr.eval(column)
+}
+
+// comparison compares a field with a value, which is an int64 for ids, a time.Time for timestamps
+// and otherwise a string, as stored
+type comparison struct {
+	field filterField
+	op    string
+	value interface{}
+	// like matches text values with wildcards
+	like *regexp.Regexp
+}
+
+func (c *comparison) sql(args []interface{}) (string, []interface{}) {
+	column := `"` + c.field.column + `"`
+	switch {
+	case c.like != nil:
+		pattern := strings.Replace(likeEscaper.Replace(c.value.(string)), "*", "%", -1)
+		return "(" + column + ` LIKE ? ESCAPE '\')`, append(args, pattern)
+	case c.field.kind == filterTime:
+		// live users have no deleteTime, which matches no comparison even when negated
+		return "(" + column + " IS NOT NULL AND " + column + " " + c.op + " ?)", append(args, c.value)
+	}
+	return "(" + column + " " + c.op + " ?)", append(args, c.value)
+}
+
+func (c *comparison) eval(column func(string) string) bool {
+	value := column(c.field.column)
+	switch c.field.kind {
+	case filterID:
+		id, _ := strconv.ParseInt(value, 10, 32)
+		return compare(c.op, id-c.value.(int64))
+	case filterTime:
+		if value == "" {
+			return false
+		}
+		ts, _ := time.Parse(time.RFC3339Nano, value)
+		return compare(c.op, int64(ts.Sub(c.value.(time.Time))))
+	}
+	if c.like != nil {
+		return c.like.MatchString(value)
+	}
+	return value == c.value
+}
+
+// compare reports whether the comparison holds for a difference between a value and the value compared with
+func compare(op string, difference int64) bool {
+	switch op {
+	case "<":
+		return difference < 0
+	case "<=":
+		return difference <= 0
+	case ">":
+		return difference > 0
+	case ">=":
+		return difference >= 0
+	}
+	return difference == 0
+}
+
+// inExpr requires a field to have one of the values
+type inExpr struct {
+	field  filterField
+	values []interface{}
+}
+
+func (e *inExpr) sql(args []interface{}) (string, []interface{}) {
+	placeholders := strings.Repeat("?, ", len(e.values))
+	return `("` + e.field.column + `" IN (` + placeholders[:len(placeholders)-2] + `))`, append(args, e.values...)
+}
+
+func (e *inExpr) eval(column func(string) string) bool {
+	for _, v := range e.values {
+		if (&comparison{field: e.field, op: "=", value: v}).eval(column) {
+			return true
+		}
+	}
+	return false
+}
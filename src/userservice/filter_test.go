@@ -0,0 +1,85 @@
+package userservice
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/beldin0/users/src/user"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	compile := func(t *testing.T, filter string) (string, []interface{}) {
+		f, err := ParseFilter(filter)
+		require.NoError(t, err)
+		return f.expr.sql(nil)
+	}
+
+	t.Run("precedence", func(t *testing.T) {
+		// OR binds more tightly than AND, and adjacent restrictions must all match
+		where, args := compile(t, `country = "uk" AND nickname:"ali*" OR email:"*@acme.com" NOT id IN (1, 2)`)
+		require.Equal(t, `(("country" = ?) AND ((("nickname_lower" LIKE ? ESCAPE '\') OR ("email" LIKE ? ESCAPE '\')) AND NOT ("id" IN (?, ?))))`, where)
+		require.Equal(t, []interface{}{"GB", "ali%", "%@acme.com", int64(1), int64(2)}, args)
+	})
+
+	t.Run("comparisons", func(t *testing.T) {
+		where, args := compile(t, `(id >= 10 AND id<20) delete_time < "2020-01-02T15:04:05+01:00" firstName != 'O\'Brien%'`)
+		require.Equal(t, `(((("id" >= ?) AND ("id" < ?)) AND ("deleted_at" IS NOT NULL AND "deleted_at" < ?)) AND NOT ("first_name_lower" = ?))`, where)
+		require.Equal(t, []interface{}{int64(10), int64(20), time.Date(2020, 1, 2, 14, 4, 5, 0, time.UTC), "o'brien%"}, args)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		f, err := ParseFilter("  ")
+		require.NoError(t, err)
+		require.Nil(t, f.expr)
+	})
+
+	for filter, message := range map[string]string{
+		`country = `:                      "invalid filter at position 11: expected a value for country but found end of filter",
+		`name = "alan"`:                   "invalid filter at position 1: unknown field 'name', expected one of id, firstName, lastName, nickname, email, country or deleteTime",
+		`email < "b"`:                     "invalid filter at position 7: email can only be compared with =, != or :",
+		`id = abc`:                        "invalid filter at position 6: id must be an integer",
+		`deleteTime > 2020`:               `invalid filter at position 14: deleteTime must be an RFC 3339 timestamp in quotes, e.g. "2020-01-02T15:04:05Z"`,
+		`(country = GB`:                   "invalid filter at position 14: expected ')' but found end of filter",
+		`country = GB)`:                   "invalid filter at position 13: unexpected ')'",
+		`nickname = "ali`:                 "invalid filter at position 12: unterminated string",
+		`country IN (GB, "F*")`:           "invalid filter at position 17: wildcards cannot be used with IN",
+		`country = GB AND OR email = "a"`: "invalid filter at position 18: expected a field but found 'OR'",
+		`country ! GB`:                    "invalid filter at position 9: expected != but found '!'",
+	} {
+		_, err := ParseFilter(filter)
+		var e *Error
+		require.True(t, errors.As(err, &e), filter)
+		require.Equal(t, "INVALID_FILTER", e.Reason)
+		require.Equal(t, message, e.Message)
+	}
+}
+
+func TestFilterMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	for _, u := range []*user.User{
+		{Nickname: "alice", Email: "alice@acme.com", Country: "GB"},
+		{Nickname: "alison", Email: "alison@example.com", Country: "GB"},
+		{Nickname: "bob", Email: "bob@acme.com", Country: "FR"},
+	} {
+		require.NoError(t, s.Add(u, Audit{}))
+	}
+	filter, err := ParseFilter(`country = "GB" AND (nickname:"ali*" OR email:"*@acme.com") NOT nickname = alison`)
+	require.NoError(t, err)
+	users, err := s.Get(Search().Filter(filter))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(users))
+	require.Equal(t, "alice", users[0].Nickname)
+
+	filter, err = ParseFilter(`id > 1 deleteTime < "2100-01-01T00:00:00Z"`)
+	require.NoError(t, err)
+	users, err = s.Get(Search().Filter(filter).IncludeDeleted())
+	require.NoError(t, err)
+	require.Equal(t, 0, len(users)) // assert that live users have no deleteTime to compare
+	require.NoError(t, s.Delete(3, 1, Audit{}))
+	users, err = s.Get(Search().Filter(filter).IncludeDeleted())
+	require.NoError(t, err)
+	require.Equal(t, 1, len(users))
+	require.Equal(t, "bob", users[0].Nickname)
+}
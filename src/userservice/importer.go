@@ -0,0 +1,170 @@
+package userservice
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/beldin0/users/src/logging"
+	"github.com/beldin0/users/src/password"
+	"github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userio"
+)
+
+const (
+	// importBatch is the number of users stored at a time by Import
+	importBatch = 500
+	// importMaxErrors limits the errors listed in an ImportSummary, which still counts every failed user
+	importMaxErrors = 1000
+)
+
+// importRow is a user waiting to be stored by Import
+type importRow struct {
+	line int
+	user *user.User
+}
+
+// importer accumulates the outcome of an import
+type importer struct {
+	store   Store
+	audit   Audit
+	dryRun  bool
+	summary *user.ImportSummary
+	pending []importRow
+	// emails and nicknames are those read so far, as duplicates within an import
+	// are only found by the store when they are in the same batch
+	emails    map[string]bool
+	nicknames map[string]bool
+}
+
+// Import adds the users read from r, storing them in batches, and returns a summary of the users which
+// could not be added and why. Users must have a nickname, email and password, which are unique as for Add,
+// and every user that is added is audited and published as by Add. A dry run checks the users against the
+// store without storing them.
+// An error is only returned if r or the store fails, in which case the batch being stored is not added.
+func (s *Service) Import(r userio.ImportReader, dryRun bool, a Audit) (*user.ImportSummary, error) {
+	imp := &importer{
+		store:     s.store,
+		audit:     a,
+		dryRun:    dryRun,
+		summary:   &user.ImportSummary{DryRun: dryRun},
+		emails:    map[string]bool{},
+		nicknames: map[string]bool{},
+	}
+	for {
+		u, line, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *userio.RowError
+		if errors.As(err, &rowErr) {
+			imp.fail(rowErr.Line, rowErr.Err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := imp.add(line, u); err != nil {
+			imp.fail(line, err)
+			continue
+		}
+		if len(imp.pending) == importBatch {
+			if err := imp.flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := imp.flush(); err != nil {
+		return nil, err
+	}
+	// rows which cannot be read fail before the batch holding earlier rows is stored
+	sort.SliceStable(imp.summary.Errors, func(i, j int) bool {
+		return imp.summary.Errors[i].Line < imp.summary.Errors[j].Line
+	})
+	logging.NewLogger().Sugar().
+		With("function", "import").
+		With("dryRun", dryRun).
+		With("imported", imp.summary.Imported).
+		With("failed", imp.summary.Failed).
+		Info("users imported")
+	return imp.summary, nil
+}
+
+// add validates the user, hashes its password, and adds it to the pending batch
+func (imp *importer) add(line int, u *user.User) error {
+	if err := validateImport(u); err != nil {
+		return err
+	}
+	email, nickname := strings.ToLower(u.Email), strings.ToLower(u.Nickname)
+	if imp.emails[email] {
+		return ErrDuplicate.WithCause(errors.New("email " + u.Email + " is repeated"))
+	}
+	if imp.nicknames[nickname] {
+		return ErrDuplicate.WithCause(errors.New("nickname " + u.Nickname + " is repeated"))
+	}
+	imp.emails[email], imp.nicknames[nickname] = true, true
+	hashed, err := password.Hash(u.Password)
+	if err != nil {
+		return err
+	}
+	u.Id, u.Etag, u.DeleteTime = 0, "", nil
+	u.Password = hashed
+	imp.pending = append(imp.pending, importRow{line: line, user: u})
+	return nil
+}
+
+// flush stores the pending batch
+func (imp *importer) flush() error {
+	if len(imp.pending) == 0 {
+		return nil
+	}
+	users := make([]*user.User, len(imp.pending))
+	for i, row := range imp.pending {
+		users[i] = row.user
+	}
+	errs, err := imp.store.Import(users, imp.audit, imp.dryRun)
+	if err != nil {
+		return err
+	}
+	for i, row := range imp.pending {
+		row.user.Password = ""
+		if errs[i] != nil {
+			imp.fail(row.line, errs[i])
+			continue
+		}
+		imp.summary.Imported++
+	}
+	imp.pending = imp.pending[:0]
+	return nil
+}
+
+// fail records that the user on the line was not imported
+func (imp *importer) fail(line int, err error) {
+	imp.summary.Failed++
+	if len(imp.summary.Errors) == importMaxErrors {
+		return
+	}
+	var e *Error
+	if !errors.As(err, &e) {
+		e = ErrInternal
+	}
+	imp.summary.Errors = append(imp.summary.Errors, &user.ImportError{
+		Line:    int32(line),
+		Reason:  e.Reason,
+		Message: e.Message,
+	})
+}
+
+// validateImport checks that an imported user has the fields needed to log in
+func validateImport(u *user.User) error {
+	switch {
+	case u.Nickname == "":
+		return userio.InvalidArgumentError("MISSING_NICKNAME", "nickname is required")
+	case u.Email == "":
+		return userio.InvalidArgumentError("MISSING_EMAIL", "email is required")
+	case u.Password == "":
+		return userio.InvalidArgumentError("MISSING_PASSWORD", "password is required")
+	}
+	return nil
+}
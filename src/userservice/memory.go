@@ -0,0 +1,638 @@
+package userservice
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beldin0/users/src/user"
+)
+
+// MemoryStore is a Store holding users in memory, for running the service without a database.
+// It honours the same uniqueness constraints and matching rules as the database backed stores.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	nextID  int32
+	users   map[int32]insertUser
+	events  []auditRecord
+	outbox  []outboxRecord
+	changes *broadcaster
+
+	nextWebhookID  int32
+	webhooks       map[int32]webhookRecord
+	nextDeliveryID int64
+	deliveries     map[int64]deliveryRecord
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:      make(map[int32]insertUser),
+		changes:    newBroadcaster(),
+		webhooks:   make(map[int32]webhookRecord),
+		deliveries: make(map[int64]deliveryRecord),
+	}
+}
+
+// Add inserts a new user, setting its Id
+func (s *MemoryStore) Add(u *user.User, a Audit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := toInsert(u)
+	if err := s.unique(record, 0); err != nil {
+		return err
+	}
+	s.nextID++
+	u.Id = s.nextID
+	id := u.Id
+	record.UserID = &id
+	record.Version = 1
+	u.Etag = etag(record.Version)
+	s.users[id] = record
+	stored := record.toUser(id)
+	return s.record(a, OpCreate, id, stored, diff(nil, stored, true))
+}
+
+// Import adds each of the users as by Add, returning the error adding each user.
+// A dry run checks the users against those stored and earlier in the batch without adding them.
+func (s *MemoryStore) Import(users []*user.User, a Audit, dryRun bool) ([]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errs := make([]error, len(users))
+	emails, nicknames := map[string]bool{}, map[string]bool{}
+	for i, u := range users {
+		record := toInsert(u)
+		if err := s.unique(record, 0); err != nil {
+			errs[i] = err
+			continue
+		}
+		if emails[record.Email] || nicknames[record.NicknameLower] {
+			errs[i] = ErrDuplicate
+			continue
+		}
+		emails[record.Email], nicknames[record.NicknameLower] = true, true
+		if dryRun {
+			continue
+		}
+		s.nextID++
+		u.Id = s.nextID
+		id := u.Id
+		record.UserID = &id
+		record.Version = 1
+		u.Etag = etag(record.Version)
+		s.users[id] = record
+		stored := record.toUser(id)
+		if err := s.record(a, OpCreate, id, stored, diff(nil, stored, true)); err != nil {
+			return nil, err
+		}
+	}
+	return errs, nil
+}
+
+// Get returns the users matching the SearchOptions, ordered by id
+func (s *MemoryStore) Get(o *SearchOptions) ([]*user.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sorted(s.matching(o), "id", nil), nil
+}
+
+// BatchGet returns the live users with any of the ids
+func (s *MemoryStore) BatchGet(ids []int32) ([]*user.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := []*user.User{}
+	for _, id := range ids {
+		if record, ok := s.users[id]; ok && record.DeletedAt == nil {
+			users = append(users, record.toUser(id))
+		}
+	}
+	return users, nil
+}
+
+// Export calls f with each user matching the SearchOptions in order of id.
+// The users are already held in memory, so they are copied rather than holding the lock while f runs.
+func (s *MemoryStore) Export(o *SearchOptions, f func(*user.User) error) error {
+	users, _ := s.Get(o)
+	for _, u := range users {
+		if err := f(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search returns a single page of the users matching the SearchOptions
+func (s *MemoryStore) Search(o *SearchOptions, p Page) (*Results, error) {
+	size, column, after, err := p.resolve()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	matching := s.matching(o)
+	scores := map[int32]float32{}
+	if o.query() != "" {
+		for _, id := range matching {
+			scores[id] = o.relevance(s.users[id].column)
+		}
+	}
+	value := func(id int32) string {
+		if column == relevanceColumn {
+			return formatScore(scores[id])
+		}
+		return s.users[id].column(column)
+	}
+	results := &Results{Users: []*user.User{}, Total: len(matching)}
+	for _, u := range s.sorted(matching, column, value) {
+		if after != nil && !after.precedes(column, value(u.Id), u.Id) {
+			continue
+		}
+		if len(results.Users) == size {
+			last := results.Users[size-1]
+			results.NextToken = nextCursor(p.OrderBy, column, last, scores[last.Id])
+			break
+		}
+		results.Users = append(results.Users, u)
+		if o.query() != "" {
+			results.Scores = append(results.Scores, scores[u.Id])
+		}
+	}
+	return results, nil
+}
+
+// Modify replaces the details of the user with u.Id, returning the user as stored
+func (s *MemoryStore) Modify(u *user.User, a Audit) (*user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.users[u.Id]
+	if !ok || existing.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	record := toInsert(u)
+	if record.Version != existing.Version {
+		return nil, ErrEtagMismatch
+	}
+	if err := s.unique(record, u.Id); err != nil {
+		return nil, err
+	}
+	if record.Password == "" {
+		record.Password = existing.Password
+	}
+	id := u.Id
+	record.UserID = &id
+	record.Version++
+	s.users[id] = record
+	stored := record.toUser(id)
+	return stored, s.record(a, OpModify, id, stored, diff(existing.toUser(id), stored, u.Password != ""))
+}
+
+// Update sets only the named columns of the user with u.Id, returning the user as stored
+func (s *MemoryStore) Update(u *user.User, columns []string, a Audit) (*user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.users[u.Id]
+	if !ok || record.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	before := record.toUser(u.Id)
+	updated := toInsert(u)
+	if u.Etag != "" && updated.Version != record.Version {
+		return nil, ErrEtagMismatch
+	}
+	for _, column := range columns {
+		record.assign(column, updated)
+	}
+	if err := s.unique(record, u.Id); err != nil {
+		return nil, err
+	}
+	record.Version++
+	s.users[u.Id] = record
+	stored := record.toUser(u.Id)
+	return stored, s.record(a, OpUpdate, u.Id, stored, diff(before, stored, u.Password != ""))
+}
+
+// Delete marks the user with the id as deleted, provided that it is still at version
+func (s *MemoryStore) Delete(userID int32, version int64, a Audit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.users[userID]
+	if !ok || existing.DeletedAt != nil {
+		return ErrNotFound
+	}
+	if existing.Version != version {
+		return ErrEtagMismatch
+	}
+	before := existing.toUser(userID)
+	now := time.Now().UTC()
+	existing.DeletedAt = &now
+	existing.Version++
+	s.users[userID] = existing
+	after := existing.toUser(userID)
+	return s.record(a, OpDelete, userID, after, diff(before, after, false))
+}
+
+// Restore undoes the deletion of the user with the id, returning the user as stored
+func (s *MemoryStore) Restore(userID int32, a Audit) (*user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.users[userID]
+	if !ok || record.DeletedAt == nil {
+		return nil, ErrNotDeleted
+	}
+	if err := s.unique(record, userID); err != nil {
+		return nil, err
+	}
+	before := record.toUser(userID)
+	record.DeletedAt = nil
+	record.Version++
+	s.users[userID] = record
+	stored := record.toUser(userID)
+	return stored, s.record(a, OpRestore, userID, stored, diff(before, stored, false))
+}
+
+// Purge permanently removes the users deleted before the time, returning how many were removed
+func (s *MemoryStore) Purge(before time.Time, a Audit) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	batch := []recordedChange{}
+	for id, record := range s.users {
+		if record.DeletedAt != nil && record.DeletedAt.Before(before) {
+			batch = append(batch, recordedChange{userID: id, changes: []change{}})
+		}
+	}
+	if err := s.recordMany(a, OpPurge, batch); err != nil {
+		return 0, err
+	}
+	for _, c := range batch {
+		delete(s.users, c.userID)
+	}
+	return len(batch), nil
+}
+
+// AuditEvents returns a single page of the audit events of the user
+func (s *MemoryStore) AuditEvents(userID int32, start, end time.Time, p Page) (*AuditResults, error) {
+	size, err := p.size()
+	if err != nil {
+		return nil, err
+	}
+	after, err := tokenID(p.Token)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := []auditRecord{}
+	for _, r := range s.events {
+		if len(records) > size {
+			break
+		}
+		if r.UserID != userID || r.ID <= after ||
+			(!start.IsZero() && r.CreatedAt.Before(start)) || (!end.IsZero() && !r.CreatedAt.Before(end)) {
+			continue
+		}
+		records = append(records, r)
+	}
+	return auditResults(records, size)
+}
+
+// Unpublished returns up to limit events from the outbox which have not been published, in the order they were written,
+// other than the events of the users in skip
+func (s *MemoryStore) Unpublished(limit int, skip []int32) ([]*user.UserEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	skipped := map[int32]bool{}
+	for _, id := range skip {
+		skipped[id] = true
+	}
+	records := []outboxRecord{}
+	for _, r := range s.outbox {
+		if len(records) == limit {
+			break
+		}
+		if r.PublishedAt == nil && !skipped[r.UserID] {
+			records = append(records, r)
+		}
+	}
+	return toEvents(records)
+}
+
+// MarkPublished records that the events with the ids have been published
+func (s *MemoryStore) MarkPublished(ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	for _, id := range ids {
+		if id >= 1 && id <= int64(len(s.outbox)) {
+			s.outbox[id-1].PublishedAt = &now
+		}
+	}
+	return nil
+}
+
+// record records a change to a user in the audit log, and writes the event it publishes to the outbox.
+// stored is the user after the change.
+func (s *MemoryStore) record(a Audit, op string, userID int32, stored *user.User, changes []change) error {
+	return s.recordMany(a, op, []recordedChange{{userID: userID, stored: stored, changes: changes}})
+}
+
+// recordMany records the changes to a batch of users like record, waking watchers once for the whole batch.
+// Nothing is recorded if any change cannot be.
+func (s *MemoryStore) recordMany(a Audit, op string, batch []recordedChange) error {
+	audits := make([]auditRecord, 0, len(batch))
+	events := make([]outboxRecord, 0, len(batch))
+	for _, c := range batch {
+		audit, err := newAuditRecord(a, op, c.userID, c.changes)
+		if err != nil {
+			return err
+		}
+		audits = append(audits, audit)
+		event, err := newOutboxRecord(op, c.userID, c.stored, c.changes)
+		if err != nil {
+			return err
+		}
+		if event != nil {
+			events = append(events, *event)
+		}
+	}
+	for _, audit := range audits {
+		audit.ID = int64(len(s.events) + 1)
+		s.events = append(s.events, audit)
+	}
+	for _, event := range events {
+		event.ID = int64(len(s.outbox) + 1)
+		s.outbox = append(s.outbox, event)
+		for _, w := range s.webhooks {
+			if w.wants(event.EventType) {
+				s.nextDeliveryID++
+				d := newDeliveryRecord(w.ID, event.ID)
+				d.ID = s.nextDeliveryID
+				s.deliveries[d.ID] = d
+			}
+		}
+	}
+	if len(events) > 0 {
+		s.changes.notify()
+	}
+	return nil
+}
+
+// Events returns up to limit events from the outbox written after the event with the id, in the order they were written
+func (s *MemoryStore) Events(after int64, limit int) ([]*user.UserEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	// the ids of the outbox are its indexes from 1
+	if after < 0 || after >= int64(len(s.outbox)) {
+		return []*user.UserEvent{}, nil
+	}
+	records := s.outbox[after:]
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	return toEvents(records)
+}
+
+// LastEventID returns the id of the latest event in the outbox, or 0 if there are none
+func (s *MemoryStore) LastEventID() (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return int64(len(s.outbox)), nil
+}
+
+// Changed returns a channel which is closed when the next event is written to the outbox
+func (s *MemoryStore) Changed() <-chan struct{} {
+	return s.changes.wait()
+}
+
+// AddWebhook inserts a new webhook, setting its Id and CreateTime
+func (s *MemoryStore) AddWebhook(w *user.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := newWebhookRecord(w)
+	s.nextWebhookID++
+	record.ID = s.nextWebhookID
+	s.webhooks[record.ID] = record
+	w.Id = record.ID
+	w.CreateTime = record.toWebhook().CreateTime
+	return nil
+}
+
+// Webhooks returns every webhook, without their secrets
+func (s *MemoryStore) Webhooks() ([]*user.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	webhooks := []*user.Webhook{}
+	for _, w := range s.webhooks {
+		webhooks = append(webhooks, w.toWebhook())
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].Id < webhooks[j].Id })
+	return webhooks, nil
+}
+
+// DeleteWebhook removes the webhook with the id along with its deliveries
+func (s *MemoryStore) DeleteWebhook(id int32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.webhooks[id]; !ok {
+		return ErrWebhookNotFound
+	}
+	delete(s.webhooks, id)
+	for deliveryID, d := range s.deliveries {
+		if d.WebhookID == id {
+			delete(s.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+// DueDeliveries returns up to limit pending deliveries due to be attempted at now, oldest first
+func (s *MemoryStore) DueDeliveries(now time.Time, limit int) ([]Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	deliveries := []Delivery{}
+	for _, r := range s.sortedDeliveries() {
+		if len(deliveries) == limit {
+			break
+		}
+		if r.State != DeliveryPending || r.NextAttemptAt.After(now) {
+			continue
+		}
+		w := s.webhooks[r.WebhookID]
+		r.URL, r.Secret, r.Payload = w.URL, w.Secret, s.outbox[r.EventID-1].Payload
+		d, err := r.toDelivery()
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt records the outcome of an attempt to make a delivery
+func (s *MemoryStore) RecordAttempt(id int64, state string, attempts int, lastError string, next time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d, ok := s.deliveries[id]; ok {
+		d.State, d.Attempts, d.LastError, d.NextAttemptAt = state, attempts, lastError, next
+		s.deliveries[id] = d
+	}
+	return nil
+}
+
+// DeadLetters returns a single page of the deliveries which failed every attempt, oldest first
+func (s *MemoryStore) DeadLetters(webhookID int32, p Page) (*DeadLetters, error) {
+	size, err := p.size()
+	if err != nil {
+		return nil, err
+	}
+	after, err := tokenID(p.Token)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := []deliveryRecord{}
+	for _, r := range s.sortedDeliveries() {
+		if len(records) > size {
+			break
+		}
+		if r.State != DeliveryDead || r.ID <= after || (webhookID != 0 && r.WebhookID != webhookID) {
+			continue
+		}
+		r.EventType = s.outbox[r.EventID-1].EventType
+		records = append(records, r)
+	}
+	return deadLetters(records, size), nil
+}
+
+// Replay returns a dead letter to pending, to be attempted straight away with no failed attempts
+func (s *MemoryStore) Replay(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.deliveries[id]
+	if !ok || d.State != DeliveryDead {
+		return ErrDeadLetterNotFound
+	}
+	d.State, d.Attempts, d.NextAttemptAt = DeliveryPending, 0, time.Now().UTC()
+	s.deliveries[id] = d
+	return nil
+}
+
+// sortedDeliveries returns the deliveries ordered by id
+func (s *MemoryStore) sortedDeliveries() []deliveryRecord {
+	deliveries := make([]deliveryRecord, 0, len(s.deliveries))
+	for _, d := range s.deliveries {
+		deliveries = append(deliveries, d)
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries
+}
+
+// Credentials returns the id and password hash of the user with the email or nickname login
+func (s *MemoryStore) Credentials(login string) (int32, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	login = strings.ToLower(login)
+	for id, record := range s.users {
+		if record.DeletedAt != nil {
+			continue
+		}
+		if record.Email == login || record.NicknameLower == login {
+			return id, record.Password, nil
+		}
+	}
+	return 0, "", ErrInvalidCredentials
+}
+
+// unique checks the record against the unique columns of every other live user
+func (s *MemoryStore) unique(record insertUser, self int32) error {
+	for id, existing := range s.users {
+		if id == self || existing.DeletedAt != nil {
+			continue
+		}
+		if existing.Email == record.Email {
+			return ErrDuplicate.WithCause(errors.New("email " + record.Email + " exists"))
+		}
+		if existing.NicknameLower == record.NicknameLower {
+			return ErrDuplicate.WithCause(errors.New("nickname " + record.Nickname + " exists"))
+		}
+	}
+	return nil
+}
+
+// matching returns the ids of the users matching the SearchOptions
+func (s *MemoryStore) matching(o *SearchOptions) []int32 {
+	ids := []int32{}
+	for id, record := range s.users {
+		if o.matches(record.column) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// sorted returns the users with the ids, ordered by their values of column then id,
+// with the most relevant first when ordering by relevance. value is not needed to order by id.
+func (s *MemoryStore) sorted(ids []int32, column string, value func(int32) string) []*user.User {
+	sort.Slice(ids, func(i, j int) bool {
+		if column == "id" {
+			return ids[i] < ids[j]
+		}
+		a, b := value(ids[i]), value(ids[j])
+		switch {
+		case a == b:
+			return ids[i] < ids[j]
+		case column == relevanceColumn:
+			return parseScore(a) > parseScore(b)
+		}
+		return a < b
+	})
+	users := make([]*user.User, len(ids))
+	for i, id := range ids {
+		users[i] = s.users[id].toUser(id)
+	}
+	return users
+}
+
+// column returns the value of the named database column of the record
+func (i insertUser) column(name string) string {
+	switch name {
+	case "id":
+		if i.UserID == nil {
+			return ""
+		}
+		return fmt.Sprint(*i.UserID)
+	case "first_name_lower":
+		return i.FirstnameLower
+	case "last_name_lower":
+		return i.LastnameLower
+	case "nickname_lower":
+		return i.NicknameLower
+	case "email":
+		return i.Email
+	case "country":
+		return i.Country
+	case "deleted_at":
+		if i.DeletedAt == nil {
+			return ""
+		}
+		return i.DeletedAt.Format(time.RFC3339Nano)
+	}
+	return ""
+}
+
+// toUser returns the user held by the record, without its password
+func (i insertUser) toUser(id int32) *user.User {
+	return &user.User{
+		Id:         id,
+		FirstName:  i.Firstname,
+		LastName:   i.Lastname,
+		Nickname:   i.Nickname,
+		Email:      i.Email,
+		Country:    i.Country,
+		Etag:       etag(i.Version),
+		DeleteTime: deleteTime(i.DeletedAt),
+	}
+}
@@ -0,0 +1,121 @@
+package userservice
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/beldin0/users/src/user"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	alan := &user.User{FirstName: "Alan", Nickname: "Alan112", Email: "alan@faceit.com", Country: "uk", Password: "hash"}
+	require.NoError(t, s.Add(alan, Audit{}))
+	require.Equal(t, int32(1), alan.Id)
+
+	t.Run("unique email and nickname", func(t *testing.T) {
+		err := s.Add(&user.User{Nickname: "other", Email: "ALAN@faceit.com"}, Audit{})
+		require.True(t, errors.Is(err, ErrDuplicate))
+		err = s.Add(&user.User{Nickname: "alan112", Email: "other@faceit.com"}, Audit{})
+		require.True(t, errors.Is(err, ErrDuplicate))
+	})
+
+	t.Run("partial match", func(t *testing.T) {
+		users, err := s.Get(Search().Nickname("AN11").Country("UK"))
+		require.NoError(t, err)
+		require.Equal(t, 1, len(users))
+		require.Equal(t, "Alan112", users[0].Nickname)
+		require.Equal(t, "", users[0].Password)
+
+		users, err = s.Get(Search().Nickname("an_1"))
+		require.NoError(t, err)
+		require.Equal(t, 0, len(users))
+	})
+
+	t.Run("modify keeps password", func(t *testing.T) {
+		stored, err := s.Modify(&user.User{Id: alan.Id, FirstName: "John", Nickname: "Alan112", Email: "alan@faceit.com", Etag: alan.Etag}, Audit{})
+		require.NoError(t, err)
+		require.Equal(t, "John", stored.FirstName)
+		require.Equal(t, "", stored.Password)
+		require.NotEqual(t, alan.Etag, stored.Etag)
+		id, hash, err := s.Credentials("alan112")
+		require.NoError(t, err)
+		require.Equal(t, alan.Id, id)
+		require.Equal(t, "hash", hash)
+	})
+
+	t.Run("update only listed columns", func(t *testing.T) {
+		stored, err := s.Update(&user.User{Id: alan.Id, Nickname: "AlanS"}, []string{"nickname", "nickname_lower"}, Audit{})
+		require.NoError(t, err)
+		require.Equal(t, "AlanS", stored.Nickname)
+		require.Equal(t, "John", stored.FirstName)
+		users, err := s.Get(Search().Nickname("alans"))
+		require.NoError(t, err)
+		require.Equal(t, 1, len(users))
+	})
+
+	t.Run("stale etag", func(t *testing.T) {
+		_, err := s.Modify(&user.User{Id: alan.Id, Nickname: "Alan112", Email: "alan@faceit.com", Etag: alan.Etag}, Audit{})
+		require.True(t, errors.Is(err, ErrEtagMismatch))
+		require.True(t, errors.Is(s.Delete(alan.Id, 1, Audit{}), ErrEtagMismatch))
+	})
+
+	t.Run("soft delete", func(t *testing.T) {
+		grace := &user.User{Nickname: "grace", Email: "grace@faceit.com", Password: "hash"}
+		require.NoError(t, s.Add(grace, Audit{}))
+		require.NoError(t, s.Delete(grace.Id, 1, Audit{}))
+		users, err := s.Get(Get(grace.Id))
+		require.NoError(t, err)
+		require.Equal(t, 0, len(users))
+		_, _, err = s.Credentials("grace")
+		require.Equal(t, ErrInvalidCredentials, err)
+
+		// the nickname of a deleted user can be reused, after which it cannot be restored
+		reused := &user.User{Nickname: "Grace", Email: "grace2@faceit.com"}
+		require.NoError(t, s.Add(reused, Audit{}))
+		_, err = s.Restore(grace.Id, Audit{})
+		require.True(t, errors.Is(err, ErrDuplicate))
+		require.NoError(t, s.Delete(reused.Id, 1, Audit{}))
+		restored, err := s.Restore(grace.Id, Audit{})
+		require.NoError(t, err)
+		require.Nil(t, restored.DeleteTime)
+
+		purged, err := s.Purge(time.Now().Add(time.Hour), Audit{})
+		require.NoError(t, err)
+		require.Equal(t, 1, purged)
+		audit, err := s.AuditEvents(reused.Id, time.Time{}, time.Time{}, Page{})
+		require.NoError(t, err)
+		require.Equal(t, OpPurge, audit.Events[len(audit.Events)-1].Operation)
+		_, err = s.Restore(reused.Id, Audit{})
+		require.True(t, errors.Is(err, ErrNotDeleted))
+	})
+
+	t.Run("missing user", func(t *testing.T) {
+		_, err := s.Modify(&user.User{Id: 999, Nickname: "nobody", Etag: "1"}, Audit{})
+		require.True(t, errors.Is(err, ErrNotFound))
+		require.True(t, errors.Is(s.Delete(999, 1, Audit{}), ErrNotFound))
+	})
+
+	t.Run("audit log", func(t *testing.T) {
+		page, err := s.AuditEvents(alan.Id, time.Time{}, time.Time{}, Page{Size: 2})
+		require.NoError(t, err)
+		require.Equal(t, 2, len(page.Events))
+		require.Equal(t, OpCreate, page.Events[0].Operation)
+		require.Equal(t, OpModify, page.Events[1].Operation)
+		for _, c := range page.Events[0].Changes {
+			if c.Field == "password" {
+				require.Equal(t, redacted, c.After)
+			}
+			require.NotEqual(t, "hash", c.After)
+		}
+
+		page, err = s.AuditEvents(alan.Id, time.Time{}, time.Time{}, Page{Size: 2, Token: page.NextToken})
+		require.NoError(t, err)
+		require.Equal(t, 1, len(page.Events))
+		require.Equal(t, OpUpdate, page.Events[0].Operation)
+		require.Equal(t, []*user.FieldChange{{Field: "nickname", Before: "Alan112", After: "AlanS"}}, page.Events[0].Changes)
+		require.Equal(t, "", page.NextToken)
+	})
+}
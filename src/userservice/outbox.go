@@ -0,0 +1,108 @@
+package userservice
+
+import (
+	"time"
+
+	"github.com/beldin0/users/src/user"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+)
+
+// The types of event written to the outbox
+const (
+	EventUserCreated = "UserCreated"
+	EventUserUpdated = "UserUpdated"
+	EventUserDeleted = "UserDeleted"
+)
+
+// EventType returns the type of the event, e.g. UserCreated
+func EventType(e *user.UserEvent) string {
+	switch e.Event.(type) {
+	case *user.UserEvent_Created:
+		return EventUserCreated
+	case *user.UserEvent_Updated:
+		return EventUserUpdated
+	case *user.UserEvent_Deleted:
+		return EventUserDeleted
+	}
+	return ""
+}
+
+// outboxRecord is a row of the outbox table, holding an encoded UserEvent
+type outboxRecord struct {
+	ID        int64     `db:"id"`
+	UserID    int32     `db:"user_id"`
+	EventType string    `db:"event_type"`
+	Payload   []byte    `db:"payload"`
+	CreatedAt time.Time `db:"created_at"`
+	// PublishedAt is only held by the MemoryStore, the database stores select unpublished events
+	PublishedAt *time.Time `db:"-"`
+}
+
+// recordedChange is the change to one of a batch of users recorded together by a store.
+// stored is the user after the change, nil if it no longer exists.
+type recordedChange struct {
+	userID  int32
+	stored  *user.User
+	changes []change
+}
+
+// newOutboxRecord returns the outbox record of the event published for an operation on a user,
+// where stored is the user after the operation. Operations which publish no event return nil.
+func newOutboxRecord(op string, userID int32, stored *user.User, changes []change) (*outboxRecord, error) {
+	now := time.Now().UTC()
+	ts, err := ptypes.TimestampProto(now)
+	if err != nil {
+		return nil, err
+	}
+	event := &user.UserEvent{UserId: userID, Time: ts}
+	switch op {
+	case OpCreate:
+		event.Event = &user.UserEvent_Created{Created: &user.UserCreated{User: stored}}
+	case OpModify, OpUpdate, OpRestore:
+		updated := &user.UserUpdated{User: stored}
+		for _, c := range changes {
+			updated.ChangedFields = append(updated.ChangedFields, c.Field)
+		}
+		event.Event = &user.UserEvent_Updated{Updated: updated}
+	case OpDelete:
+		event.Event = &user.UserEvent_Deleted{Deleted: &user.UserDeleted{Id: userID}}
+	default:
+		// purged users were already deleted, which was published at the time
+		return nil, nil
+	}
+	payload, err := proto.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return &outboxRecord{
+		UserID:    userID,
+		EventType: EventType(event),
+		Payload:   payload,
+		CreatedAt: now,
+	}, nil
+}
+
+// toEvent returns the event held by the record, with the id of the record
+func (r outboxRecord) toEvent() (*user.UserEvent, error) {
+	event := &user.UserEvent{}
+	if err := proto.Unmarshal(r.Payload, event); err != nil {
+		return nil, err
+	}
+	event.Id = r.ID
+	event.ResumeToken = idToken(r.ID)
+	return event, nil
+}
+
+// toEvents returns the events held by the records
+func toEvents(records []outboxRecord) ([]*user.UserEvent, error) {
+	events := make([]*user.UserEvent, len(records))
+	for i, r := range records {
+		event, err := r.toEvent()
+		if err != nil {
+			return nil, err
+		}
+		events[i] = event
+	}
+	return events, nil
+}
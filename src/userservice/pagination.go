@@ -0,0 +1,160 @@
+package userservice
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/beldin0/users/src/user"
+)
+
+const (
+	// DefaultPageSize is the number of users returned when a Page does not specify a Size
+	DefaultPageSize = 50
+	// MaxPageSize is the largest number of users that will be returned in a single page
+	MaxPageSize = 1000
+)
+
+// orderColumns maps the fields a search can be ordered by to the columns used for the ordering.
+// Text fields are ordered by their lowercase columns so that ordering is not case sensitive.
+var orderColumns = map[string]string{
+	"":          "id",
+	"id":        "id",
+	"last_name": "last_name_lower",
+	"lastName":  "last_name_lower",
+	"nickname":  "nickname_lower",
+	"email":     "email",
+	"relevance": relevanceColumn,
+}
+
+// relevanceColumn orders the results of a fuzzy search by their similarity to the search text, most similar first
+const relevanceColumn = "relevance"
+
+// Page requests a single page of the results of a search
+type Page struct {
+	// Size is the maximum number of users to return, DefaultPageSize if zero
+	Size int
+	// Token is the NextToken of the previous page, empty for the first page
+	Token string
+	// OrderBy is one of id, last_name, nickname, email or relevance, which is the default for fuzzy searches
+	// and otherwise id
+	OrderBy string
+}
+
+// Results is a single page of the results of a search
+type Results struct {
+	Users []*user.User
+	// NextToken requests the following page, and is empty on the last page
+	NextToken string
+	// Total is the number of users matching the search across all pages
+	Total int
+	// Scores are the relevance of each of Users to the search text of a fuzzy search, nil otherwise
+	Scores []float32
+}
+
+// cursor is the position of the last user in a page, encoded as an opaque page token
+type cursor struct {
+	OrderBy string `json:"o"`
+	Value   string `json:"v,omitempty"`
+	ID      int32  `json:"i"`
+}
+
+func (p Page) size() (int, error) {
+	switch {
+	case p.Size < 0:
+		return 0, ErrInvalidPageSize
+	case p.Size == 0:
+		return DefaultPageSize, nil
+	case p.Size > MaxPageSize:
+		return MaxPageSize, nil
+	}
+	return p.Size, nil
+}
+
+func (p Page) column() (string, error) {
+	column, ok := orderColumns[p.OrderBy]
+	if !ok {
+		return "", ErrInvalidOrderBy
+	}
+	return column, nil
+}
+
+// resolve validates the page, returning the page size, the column to order by
+// and the position to continue from, which is nil for the first page
+func (p Page) resolve() (int, string, *cursor, error) {
+	size, err := p.size()
+	if err != nil {
+		return 0, "", nil, err
+	}
+	column, err := p.column()
+	if err != nil {
+		return 0, "", nil, err
+	}
+	after, err := p.cursor()
+	if err != nil {
+		return 0, "", nil, err
+	}
+	return size, column, after, nil
+}
+
+// cursor decodes the page token, ensuring that it was issued for the same ordering
+func (p Page) cursor() (*cursor, error) {
+	if p.Token == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(p.Token)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	c := &cursor{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	column, _ := p.column()
+	if orderColumns[c.OrderBy] != column {
+		return nil, ErrInvalidPageToken
+	}
+	return c, nil
+}
+
+// keyset returns the condition selecting the users after the cursor, in the order of column
+func (c *cursor) keyset(column string) (string, []interface{}) {
+	switch column {
+	case "id":
+		return `"id" > ?`, []interface{}{c.ID}
+	case relevanceColumn:
+		score := float64(parseScore(c.Value))
+		return `("relevance" < ? OR ("relevance" = ? AND "id" > ?))`, []interface{}{score, score, c.ID}
+	}
+	return `("` + column + `", "id") > (?, ?)`, []interface{}{c.Value, c.ID}
+}
+
+// precedes reports whether the cursor comes before the user with the id and column value,
+// matching the ordering of keyset
+func (c *cursor) precedes(column, value string, id int32) bool {
+	if column == "id" || value == c.Value {
+		return id > c.ID
+	}
+	if column == relevanceColumn {
+		return parseScore(value) < parseScore(c.Value)
+	}
+	return value > c.Value
+}
+
+// nextCursor returns the cursor positioned at u, for a search ordered by column.
+// score is the relevance of u, which positions the cursor when ordering by relevance.
+func nextCursor(orderBy, column string, u *user.User, score float32) string {
+	c := cursor{OrderBy: orderBy, ID: u.Id}
+	switch column {
+	case relevanceColumn:
+		c.Value = formatScore(score)
+	case "last_name_lower":
+		c.Value = strings.ToLower(u.LastName)
+	case "nickname_lower":
+		c.Value = strings.ToLower(u.Nickname)
+	case "email":
+		c.Value = strings.ToLower(u.Email)
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -1,5 +1,7 @@
 package userservice
 
+import "strconv"
+
 const sqlInsert = `INSERT INTO users
 (
 	first_name,
@@ -23,10 +25,32 @@ VALUES
 	:password,
 	:email,
 	:country
-)
-RETURNING id;`
+)`
+
+const sqlGet = `SELECT id, first_name, last_name, nickname, email, country, version, deleted_at FROM users`
+
+// sqlBatchGet selects the live users with any of the ids, where arrays are supported
+const sqlBatchGet = sqlGet + ` WHERE id = ANY(?) AND deleted_at IS NULL`
+
+// sqlImportedIDs selects the ids of the users imported with the emails, as COPY returns no ids
+const sqlImportedIDs = `SELECT id, email FROM users WHERE email = ANY(?) AND deleted_at IS NULL`
+
+// sqlFetchExport fetches the next exportBatch users from the cursor declared by SQLStore.Export
+var sqlFetchExport = `FETCH ` + strconv.Itoa(exportBatch) + ` FROM export_users`
+
+const sqlCredentials = `SELECT id, password FROM users WHERE (email=? OR nickname_lower=?) AND deleted_at IS NULL`
 
-const sqlGet = `SELECT id, first_name, last_name, nickname, password, email, country FROM users`
+// sqlReturning returns the sqlGet columns of modified rows, where supported
+const sqlReturning = ` RETURNING id, first_name, last_name, nickname, email, country, version, deleted_at`
+
+const sqlCount = `SELECT COUNT(*) FROM users`
+
+// sqlFullName is the lowercase full name of a user, as indexed for fuzzy searches
+const sqlFullName = `(first_name_lower || ' ' || last_name_lower)`
+
+// sqlRelevance selects the sqlGet columns and the relevance of the users matched by a fuzzy search, from a subquery
+// selecting every column and the relevance, so that pages can be keyed on the relevance
+const sqlRelevance = `SELECT id, first_name, last_name, nickname, email, country, version, deleted_at, relevance FROM (SELECT *, `
 
 const sqlModify = `UPDATE users SET
 	first_name=:first_name,
@@ -35,9 +59,144 @@ const sqlModify = `UPDATE users SET
 	last_name_lower=:last_name_lower,
 	nickname=:nickname,
 	nickname_lower=:nickname_lower,
-	password=:password,
+	password=COALESCE(NULLIF(:password, ''), password),
 	email=:email,
-	country=:country
-	WHERE id=:id`
+	country=:country,
+	version=version+1
+	WHERE id=:id AND version=:version AND deleted_at IS NULL`
+
+// sqlUpdate is completed by updateQuery with the columns of a partial update
+const sqlUpdate = `UPDATE users SET `
+
+// sqlDelete marks a user as deleted, keeping the row so that it can be restored until it is purged
+const sqlDelete = `UPDATE users SET deleted_at=?, version=version+1 WHERE id=? AND version=? AND deleted_at IS NULL`
+
+// sqlVersion finds whether a user that was not modified is missing or has a different version
+const sqlVersion = `SELECT version FROM users WHERE id=? AND deleted_at IS NULL`
+
+const sqlRestore = `UPDATE users SET deleted_at=NULL, version=version+1 WHERE id=:id AND deleted_at IS NOT NULL`
+
+const sqlPurge = `DELETE FROM users WHERE deleted_at < ?`
+
+// sqlPurgeable finds the users that sqlPurge will remove, for databases without RETURNING
+const sqlPurgeable = `SELECT id FROM users WHERE deleted_at < ?`
+
+const sqlInsertAudit = `INSERT INTO user_audit
+(
+	user_id,
+	actor,
+	operation,
+	changes,
+	request_id,
+	created_at
+)
+VALUES
+(
+	:user_id,
+	:actor,
+	:operation,
+	:changes,
+	:request_id,
+	:created_at
+)`
+
+// maxParams is the most parameters a query may have, the limit of SQLite before 3.32
+const maxParams = 999
+
+// sqlInsertAuditValues, sqlInsertOutboxValues and sqlInsertDeliveryValues are completed by insertMany
+// with the values of each row, in the order of the columns listed
+const (
+	sqlInsertAuditValues    = `INSERT INTO user_audit (user_id, actor, operation, changes, request_id, created_at) VALUES `
+	sqlInsertOutboxValues   = `INSERT INTO outbox (user_id, event_type, payload, created_at) VALUES `
+	sqlInsertDeliveryValues = `INSERT INTO webhook_deliveries
+(webhook_id, event_id, state, attempts, last_error, next_attempt_at, created_at) VALUES `
+)
+
+const sqlAuditEvents = `SELECT id, user_id, actor, operation, changes, request_id, created_at FROM user_audit`
+
+const sqlInsertOutbox = `INSERT INTO outbox
+(
+	user_id,
+	event_type,
+	payload,
+	created_at
+)
+VALUES
+(
+	:user_id,
+	:event_type,
+	:payload,
+	:created_at
+)`
+
+const sqlUnpublished = `SELECT id, user_id, event_type, payload, created_at FROM outbox WHERE published_at IS NULL ORDER BY id LIMIT ?`
+
+// sqlUnpublishedSkipping is expanded by sqlx.In with the ids of the users whose events are skipped
+const sqlUnpublishedSkipping = `SELECT id, user_id, event_type, payload, created_at FROM outbox
+WHERE published_at IS NULL AND user_id NOT IN (?) ORDER BY id LIMIT ?`
+
+// sqlMarkPublished is expanded by sqlx.In with the ids of the published events
+const sqlMarkPublished = `UPDATE outbox SET published_at=? WHERE id IN (?)`
+
+const sqlEvents = `SELECT id, user_id, event_type, payload, created_at FROM outbox WHERE id > ? ORDER BY id LIMIT ?`
+
+const sqlLastEventID = `SELECT COALESCE(MAX(id), 0) FROM outbox`
+
+const sqlInsertWebhook = `INSERT INTO webhooks
+(
+	url,
+	events,
+	secret,
+	created_at
+)
+VALUES
+(
+	:url,
+	:events,
+	:secret,
+	:created_at
+)`
+
+const sqlWebhooks = `SELECT id, url, events, secret, created_at FROM webhooks`
+
+const sqlDeleteWebhook = `DELETE FROM webhooks WHERE id=?`
+
+const sqlDeleteDeliveries = `DELETE FROM webhook_deliveries WHERE webhook_id=?`
+
+const sqlInsertDelivery = `INSERT INTO webhook_deliveries
+(
+	webhook_id,
+	event_id,
+	state,
+	attempts,
+	last_error,
+	next_attempt_at,
+	created_at
+)
+VALUES
+(
+	:webhook_id,
+	:event_id,
+	:state,
+	:attempts,
+	:last_error,
+	:next_attempt_at,
+	:created_at
+)`
+
+const sqlDueDeliveries = `SELECT d.id, d.webhook_id, d.event_id, d.attempts, w.url, w.secret, o.payload
+FROM webhook_deliveries d
+JOIN webhooks w ON w.id=d.webhook_id
+JOIN outbox o ON o.id=d.event_id
+WHERE d.state=? AND d.next_attempt_at<=?
+ORDER BY d.id LIMIT ?`
+
+const sqlRecordAttempt = `UPDATE webhook_deliveries SET state=?, attempts=?, last_error=?, next_attempt_at=? WHERE id=?`
+
+// sqlDeadLetters is completed with the conditions and ordering of the page
+const sqlDeadLetters = `SELECT d.id, d.webhook_id, d.event_id, d.attempts, d.last_error, d.created_at, o.event_type
+FROM webhook_deliveries d
+JOIN outbox o ON o.id=d.event_id
+WHERE d.state=? AND d.id>?`
 
-const sqlDelete = `DELETE FROM users WHERE id=$1`
+const sqlReplay = `UPDATE webhook_deliveries SET state=?, attempts=0, next_attempt_at=? WHERE id=? AND state=?`
@@ -0,0 +1,106 @@
+package userservice
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/beldin0/users/src/logging"
+	"github.com/beldin0/users/src/user"
+)
+
+// relayBatch is the number of events read from the outbox at a time
+const relayBatch = 100
+
+// ErrSinkUnavailable is wrapped by the errors of a Publisher which cannot reach its sink at all,
+// as opposed to the sink rejecting a particular event
+var ErrSinkUnavailable = errors.New("event sink is unavailable")
+
+// Publisher delivers user events to other services
+type Publisher interface {
+	// Publish returns once the event has been accepted by the sink, or with an error if it may not have been
+	Publish(ctx context.Context, e *user.UserEvent) error
+}
+
+// Relay publishes the events written to the outbox of a Store.
+// Events are delivered at least once: an event is published again if the relay stops after publishing it
+// but before recording that it was published, so consumers should ignore event ids they have already seen.
+// The events of each user are published in order, so an event that fails to publish holds back the later
+// events of its user until it succeeds, while the events of other users are still published.
+// Only one Relay should run against a Store.
+type Relay struct {
+	store     Store
+	publisher Publisher
+	interval  time.Duration
+}
+
+// NewRelay returns a Relay publishing the events of the store, checking for new events every interval
+func NewRelay(store Store, publisher Publisher, interval time.Duration) *Relay {
+	return &Relay{
+		store:     store,
+		publisher: publisher,
+		interval:  interval,
+	}
+}
+
+// Run publishes events until ctx is done
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.Flush(ctx); err != nil {
+			logging.NewLogger().Sugar().With("error", err).Warn("error relaying events")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes the unpublished events in batches, returning how many were published.
+// Once an event fails to publish, the later events of its user are skipped and the following batches are read
+// past them, so that they cannot keep the events of other users from being published. Flush stops at the first
+// error wrapping ErrSinkUnavailable, leaving the remaining events for the next flush.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	blocked := map[int32]bool{}
+	skip := []int32{}
+	total := 0
+	for ctx.Err() == nil {
+		events, err := r.store.Unpublished(relayBatch, skip)
+		if err != nil {
+			return total, err
+		}
+		published := []int64{}
+		var sinkErr error
+		for _, e := range events {
+			if blocked[e.UserId] {
+				continue
+			}
+			if err := r.publisher.Publish(ctx, e); err != nil {
+				logging.NewLogger().Sugar().
+					With("event", e.Id).
+					With("userID", e.UserId).
+					With("error", err).
+					Warn("error publishing event")
+				if errors.Is(err, ErrSinkUnavailable) {
+					sinkErr = err
+					break
+				}
+				blocked[e.UserId] = true
+				skip = append(skip, e.UserId)
+				continue
+			}
+			published = append(published, e.Id)
+		}
+		if err := r.store.MarkPublished(published); err != nil {
+			return total, err
+		}
+		total += len(published)
+		if sinkErr != nil || len(events) < relayBatch {
+			return total, sinkErr
+		}
+	}
+	return total, nil
+}
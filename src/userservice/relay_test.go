@@ -0,0 +1,88 @@
+package userservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/beldin0/users/src/user"
+	"github.com/stretchr/testify/require"
+)
+
+// flaky is a Publisher failing the events of the users in fail, or every event while down
+type flaky struct {
+	fail      map[int32]bool
+	down      bool
+	attempts  int
+	published []int64
+}
+
+func (p *flaky) Publish(ctx context.Context, e *user.UserEvent) error {
+	p.attempts++
+	if p.down {
+		return fmt.Errorf("%w: connection refused", ErrSinkUnavailable)
+	}
+	if p.fail[e.UserId] {
+		return errors.New("rejected")
+	}
+	p.published = append(p.published, e.Id)
+	return nil
+}
+
+func TestRelay(t *testing.T) {
+	s := NewMemoryStore()
+	alan := &user.User{Nickname: "alan", Email: "alan@faceit.com"}
+	grace := &user.User{Nickname: "grace", Email: "grace@faceit.com"}
+	require.NoError(t, s.Add(alan, Audit{}))
+	require.NoError(t, s.Add(grace, Audit{}))
+	require.NoError(t, s.Delete(alan.Id, 1, Audit{}))
+
+	p := &flaky{fail: map[int32]bool{alan.Id: true}}
+	relay := NewRelay(s, p, 0)
+	published, err := relay.Flush(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, published)
+	require.Equal(t, []int64{2}, p.published)
+
+	// the failed events are published in order once the sink recovers, and the others are not published again
+	p.fail = nil
+	published, err = relay.Flush(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, published)
+	require.Equal(t, []int64{2, 1, 3}, p.published)
+	pending, err := s.Unpublished(relayBatch, nil)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(pending))
+}
+
+func TestRelayBlockedUser(t *testing.T) {
+	s := NewMemoryStore()
+	alan := &user.User{Nickname: "alan", Email: "alan@faceit.com"}
+	require.NoError(t, s.Add(alan, Audit{}))
+	for i := 0; i < relayBatch; i++ {
+		alan.FirstName = fmt.Sprint("Alan", i)
+		var err error
+		alan, err = s.Update(alan, []string{"first_name", "first_name_lower"}, Audit{})
+		require.NoError(t, err)
+	}
+	grace := &user.User{Nickname: "grace", Email: "grace@faceit.com"}
+	require.NoError(t, s.Add(grace, Audit{}))
+
+	// the events of other users are published past a full batch of the failing user's events
+	p := &flaky{fail: map[int32]bool{alan.Id: true}}
+	relay := NewRelay(s, p, 0)
+	published, err := relay.Flush(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, published)
+	require.Equal(t, []int64{relayBatch + 2}, p.published)
+	require.Equal(t, 2, p.attempts)
+
+	// a flush stops at the first event once the sink is unavailable
+	p = &flaky{down: true}
+	relay = NewRelay(s, p, 0)
+	published, err = relay.Flush(context.Background())
+	require.True(t, errors.Is(err, ErrSinkUnavailable))
+	require.Equal(t, 0, published)
+	require.Equal(t, 1, p.attempts)
+}
@@ -1,21 +1,78 @@
 package userservice
 
 import (
-	"errors"
 	"fmt"
+	"sort"
 	"strings"
+
+	"github.com/beldin0/users/src/country"
+	"github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userio"
 )
 
+// likeEscaper escapes the LIKE wildcard characters in user supplied values so that
+// they are matched literally. It is paired with an ESCAPE '\' clause in the query.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Match is how a search matches the value of a field
+type Match int
+
+const (
+	// MatchDefault matches ids, emails and countries exactly, and names and nicknames which contain the value
+	MatchDefault Match = iota
+	MatchExact
+	// MatchPrefix matches values starting with the searched value, which can use the indexes of the columns
+	MatchPrefix
+	MatchContains
+)
+
+// ParseMatch returns the Match of the MatchMode requested for a field, whose values are those of Match.
+// Values which MatchMode does not declare are rejected with an INVALID_MATCH error.
+func ParseMatch(field string, mode user.MatchMode) (Match, error) {
+	if _, ok := user.MatchMode_name[int32(mode)]; !ok {
+		return MatchDefault, userio.InvalidArgumentError("INVALID_MATCH",
+			fmt.Sprintf("%sMatch must be one of EXACT, PREFIX or CONTAINS", field))
+	}
+	return Match(mode), nil
+}
+
+// defaultMatches are the matches of the searched columns for MatchDefault
+var defaultMatches = map[string]Match{
+	"id":               MatchExact,
+	"first_name_lower": MatchContains,
+	"last_name_lower":  MatchContains,
+	"nickname_lower":   MatchContains,
+	"email":            MatchExact,
+	"country":          MatchExact,
+}
+
+// searchColumns maps the fields which can be searched to their columns
+var searchColumns = map[string]string{
+	"firstName":  "first_name_lower",
+	"first_name": "first_name_lower",
+	"lastName":   "last_name_lower",
+	"last_name":  "last_name_lower",
+	"nickname":   "nickname_lower",
+	"email":      "email",
+	"country":    "country",
+}
+
 // SearchOptions provides the means of searching for one or many users
 type SearchOptions struct {
-	options     map[string]string
-	searchExact bool
+	options map[string]string
+	// modes are how the values of options are matched, by column, if not by default
+	modes          map[string]Match
+	includeDeleted bool
+	// fuzzy is matched against the full names and nicknames of users by trigram similarity
+	fuzzy  string
+	filter *Filter
 }
 
 // Search begins a new search
 func Search() *SearchOptions {
 	return &SearchOptions{
 		options: make(map[string]string),
+		modes:   make(map[string]Match),
 	}
 }
 
@@ -25,13 +82,13 @@ func Get(id int32) *SearchOptions {
 		options: map[string]string{
 			"id": fmt.Sprintf("%d", id),
 		},
-		searchExact: true,
+		modes: make(map[string]Match),
 	}
 }
 
 // Country adds the specified country to the search parameters
-func (o *SearchOptions) Country(country string) *SearchOptions {
-	o.options["country"] = strings.ToUpper(country)
+func (o *SearchOptions) Country(name string) *SearchOptions {
+	o.options["country"] = country.Canonical(name)
 	return o
 }
 
@@ -59,43 +116,161 @@ func (o *SearchOptions) LastName(last string) *SearchOptions {
 	return o
 }
 
-func (o *SearchOptions) where() string {
-	if o.searchExact {
-		return o.whereExact()
+// Match sets how the value of a field, such as firstName or country, is matched.
+// Fields which cannot be searched are ignored.
+func (o *SearchOptions) Match(field string, m Match) *SearchOptions {
+	if column, ok := searchColumns[field]; ok {
+		o.modes[column] = m
 	}
-	if o == nil || len(o.options) == 0 {
+	return o
+}
+
+// mode returns how the value of the column is matched
+func (o *SearchOptions) mode(column string) Match {
+	if m := o.modes[column]; m != MatchDefault {
+		return m
+	}
+	return defaultMatches[column]
+}
+
+// Fuzzy adds text to the search parameters which is matched by similarity against the full names and nicknames
+// of users, tolerating misspellings. Only Search uses it, where results are ordered by relevance by default.
+func (o *SearchOptions) Fuzzy(text string) *SearchOptions {
+	o.fuzzy = strings.TrimSpace(text)
+	return o
+}
+
+// Filter restricts the search to the users matching a parsed filter expression
+func (o *SearchOptions) Filter(f *Filter) *SearchOptions {
+	o.filter = f
+	return o
+}
+
+// IncludeDeleted includes deleted users in the search, which are otherwise excluded
+func (o *SearchOptions) IncludeDeleted() *SearchOptions {
+	o.includeDeleted = true
+	return o
+}
+
+// values returns the search parameters, and is safe to call on a nil SearchOptions
+func (o *SearchOptions) values() map[string]string {
+	if o == nil {
+		return nil
+	}
+	return o.options
+}
+
+// query returns the fuzzy search text, and is safe to call on a nil SearchOptions
+func (o *SearchOptions) query() string {
+	if o == nil {
 		return ""
 	}
-	options := []string{}
-	for field, value := range o.options {
-		options = append(options, `"`+field+`" LIKE '%`+value+`%'`)
+	return o.fuzzy
+}
+
+// relevance returns the similarity of a user to the fuzzy search text, the greater of the similarities of its
+// full name and its nickname. column returns the user's value for a database column.
+func (o *SearchOptions) relevance(column func(string) string) float32 {
+	name := Similarity(column("first_name_lower")+" "+column("last_name_lower"), o.fuzzy)
+	if nickname := Similarity(column("nickname_lower"), o.fuzzy); nickname > name {
+		return nickname
+	}
+	return name
+}
+
+// fields returns the searched fields in a stable order so that the generated
+// queries are deterministic
+func (o *SearchOptions) fields() []string {
+	fields := make([]string, 0, len(o.options))
+	for field := range o.options {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// where compiles the SearchOptions into a WHERE clause using ? placeholders,
+// along with the arguments for those placeholders.
+// The query must be passed through Rebind before being executed.
+func (o *SearchOptions) where() (string, []interface{}) {
+	conditions, args := o.conditions()
+	if len(conditions) == 0 {
+		return "", nil
 	}
-	return " WHERE " + strings.Join(options, " AND ")
+	return whereClause(conditions), args
 }
 
-func (o *SearchOptions) whereExact() string {
-	if o == nil || len(o.options) == 0 {
+// whereClause returns a WHERE clause requiring all of the conditions, or an empty string if there are none
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
 		return ""
 	}
-	options := []string{}
-	for field, value := range o.options {
-		options = append(options, `"`+field+`"='`+value+`'`)
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// conditions returns the individual conditions of the WHERE clause, to be
+// combined with any further conditions required by the caller
+// Deleted users are excluded unless IncludeDeleted was set.
+// The fuzzy search text is matched by the store, as the matching depends on the database.
+func (o *SearchOptions) conditions() ([]string, []interface{}) {
+	conditions := []string{}
+	args := []interface{}{}
+	if o == nil || !o.includeDeleted {
+		conditions = append(conditions, `"deleted_at" IS NULL`)
+	}
+	if o == nil {
+		return conditions, args
 	}
-	return " WHERE " + strings.Join(options, " AND ")
+	for _, field := range o.fields() {
+		value := o.options[field]
+		switch o.mode(field) {
+		case MatchExact:
+			conditions = append(conditions, `"`+field+`"=?`)
+			args = append(args, value)
+		case MatchPrefix:
+			conditions = append(conditions, `"`+field+`" LIKE ? ESCAPE '\'`)
+			args = append(args, likeEscaper.Replace(value)+"%")
+		default:
+			conditions = append(conditions, `"`+field+`" LIKE ? ESCAPE '\'`)
+			args = append(args, "%"+likeEscaper.Replace(value)+"%")
+		}
+	}
+	if o.filter != nil && o.filter.expr != nil {
+		var condition string
+		condition, args = o.filter.expr.sql(args)
+		conditions = append(conditions, condition)
+	}
+	return conditions, args
 }
 
-func (o *SearchOptions) modify() (string, error) {
-	if o == nil || len(o.options) == 0 {
-		return "", errors.New("required searchoptions not provided for modify")
+// matches reports whether a user satisfies the SearchOptions, using the same rules as
+// the compiled WHERE clause. column returns the user's value for a database column.
+func (o *SearchOptions) matches(column func(string) string) bool {
+	if (o == nil || !o.includeDeleted) && column("deleted_at") != "" {
+		return false
+	}
+	if o == nil {
+		return true
 	}
-	_, email := o.options["email"]
-	_, nick := o.options["nickname_lower"]
-	_, country := o.options["country"]
-	switch {
-	case email:
-	case nick && country:
-	default:
-		return "", fmt.Errorf("required searchoptions not provided for modify: provided with %v", o.options)
+	if o.fuzzy != "" && o.relevance(column) < similarityThreshold {
+		return false
+	}
+	if o.filter != nil && o.filter.expr != nil && !o.filter.expr.eval(column) {
+		return false
+	}
+	for field, value := range o.options {
+		var match bool
+		switch o.mode(field) {
+		case MatchExact:
+			match = column(field) == value
+		case MatchPrefix:
+			match = strings.HasPrefix(column(field), value)
+		default:
+			match = strings.Contains(column(field), value)
+		}
+		if !match {
+			return false
+		}
 	}
-	return o.whereExact(), nil
+	return true
 }
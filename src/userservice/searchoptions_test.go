@@ -0,0 +1,64 @@
+package userservice
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userio"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhere(t *testing.T) {
+	t.Run("nil options", func(t *testing.T) {
+		var o *SearchOptions
+		where, args := o.where()
+		require.Equal(t, ` WHERE "deleted_at" IS NULL`, where)
+		require.Empty(t, args)
+	})
+
+	t.Run("include deleted", func(t *testing.T) {
+		where, args := Search().IncludeDeleted().where()
+		require.Equal(t, "", where)
+		require.Empty(t, args)
+	})
+
+	t.Run("default matches", func(t *testing.T) {
+		where, args := Search().Nickname("O'Brien").Country("uk").where()
+		require.Equal(t, ` WHERE "deleted_at" IS NULL AND "country"=? AND "nickname_lower" LIKE ? ESCAPE '\'`, where)
+		require.Equal(t, []interface{}{"GB", "%o'brien%"}, args)
+	})
+
+	t.Run("match modes", func(t *testing.T) {
+		where, args := Search().
+			Nickname("Al_").Match("nickname", MatchPrefix).
+			Email("alan@faceit.com").Match("email", MatchContains).
+			FirstName("Alan").Match("first_name", MatchExact).
+			where()
+		require.Equal(t, ` WHERE "deleted_at" IS NULL AND "email" LIKE ? ESCAPE '\' AND "first_name_lower"=? AND "nickname_lower" LIKE ? ESCAPE '\'`, where)
+		require.Equal(t, []interface{}{"%alan@faceit.com%", "alan", `al\_%`}, args)
+	})
+
+	t.Run("wildcards are escaped", func(t *testing.T) {
+		_, args := Search().Nickname(`100%_real\`).where()
+		require.Equal(t, []interface{}{`%100\%\_real\\%`}, args)
+	})
+
+	t.Run("exact match", func(t *testing.T) {
+		where, args := Get(12).where()
+		require.Equal(t, ` WHERE "deleted_at" IS NULL AND "id"=?`, where)
+		require.Equal(t, []interface{}{"12"}, args)
+	})
+}
+
+func TestParseMatch(t *testing.T) {
+	m, err := ParseMatch("email", user.MatchMode_PREFIX)
+	require.NoError(t, err)
+	require.Equal(t, MatchPrefix, m)
+
+	_, err = ParseMatch("email", user.MatchMode(7))
+	var invalid *userio.Error
+	require.True(t, errors.As(err, &invalid))
+	require.Equal(t, "INVALID_MATCH", invalid.Reason)
+	require.Equal(t, "emailMatch must be one of EXACT, PREFIX or CONTAINS", invalid.Message)
+}
@@ -1,43 +1,41 @@
 package userservice
 
 import (
-	"strings"
+	"time"
 
+	"github.com/beldin0/users/src/country"
 	"github.com/beldin0/users/src/logging"
+	"github.com/beldin0/users/src/password"
 	"github.com/beldin0/users/src/user"
-	"github.com/jmoiron/sqlx"
-	"github.com/pkg/errors"
+	"github.com/beldin0/users/src/userio"
 )
 
-// New returns a Service instance utilising the provided database
-func New(db *sqlx.DB) *Service {
+// New returns a Service instance utilising the provided Store
+func New(store Store) *Service {
 	return &Service{
-		db: db,
+		store: store,
 	}
 }
 
-// Service is a User Service, providing the methods to interact with the database
+// Service is a User Service, providing the methods to interact with the Store
 type Service struct {
-	db *sqlx.DB
+	store Store
 }
 
-// Add adds a new User to the database
-func (s *Service) Add(u *user.User) error {
-	rows, err := s.db.NamedQuery(sqlInsert, toInsert(u))
+// Add adds a new User to the Store
+// The plaintext password is replaced by its hash before storage, and cleared from u,
+// and the country is replaced by its canonical code as stored
+func (s *Service) Add(u *user.User, a Audit) error {
+	u.Country = country.Canonical(u.Country)
+	hashed, err := password.Hash(u.Password)
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			err = errors.Wrap(ErrDuplicate, err.Error())
-		}
-		logging.NewLogger().Sugar().With("error", err).Warn("error executing query")
 		return err
 	}
-	defer rows.Close()
-	if rows.Next() {
-		var i int32
-		if err := rows.Scan(&i); err != nil {
-			return err
-		}
-		u.Id = i
+	u.Password = hashed
+	err = s.store.Add(u, a)
+	u.Password = ""
+	if err != nil {
+		return err
 	}
 	logging.NewLogger().Sugar().
 		With("function", "add").
@@ -50,59 +48,170 @@ func (s *Service) Add(u *user.User) error {
 // a nil SearchOptions returns a list of all users
 // matches are made using LIKE so can be partial search terms
 func (s *Service) Get(o *SearchOptions) ([]*user.User, error) {
-	query := sqlGet + o.where()
-	rows, err := s.db.Query(query)
+	results, err := s.store.Get(o)
 	if err != nil {
-		logging.NewLogger().Sugar().
-			With("query", query).
-			With("error", err).
-			Warn("error executing query")
 		return nil, err
 	}
-	defer rows.Close()
-	results := []*user.User{}
-	for rows.Next() {
-		u := user.User{}
-		if err := rows.Scan(&u.Id, &u.FirstName, &u.LastName, &u.Nickname, &u.Password, &u.Email, &u.Country); err != nil {
-			logging.NewLogger().Sugar().
-				With("query", sqlGet+o.where()).
-				With("error", err).
-				Warn("error processing rows query")
-		}
-		results = append(results, &u)
-	}
 	logging.NewLogger().Sugar().
 		With("function", "get").
-		With("search", o.options).
+		With("search", o.values()).
 		With("results", len(results)).
 		Info("returning results")
-	return results, err
+	return results, nil
+}
+
+// GetByID returns the user with the id, or ErrNotFound if there is no such user
+func (s *Service) GetByID(userID int32) (*user.User, error) {
+	users, err := s.Get(Get(userID))
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, ErrNotFound
+	}
+	return users[0], nil
+}
+
+// MaxBatchSize is the most users that BatchGet will return
+const MaxBatchSize = 1000
+
+// BatchGet returns the user with each of the ids, in the same order, with nil for those which do not exist.
+// The users are read in a single query.
+func (s *Service) BatchGet(ids []int32) ([]*user.User, error) {
+	if len(ids) > MaxBatchSize {
+		return nil, ErrTooManyIDs
+	}
+	found, err := s.store.BatchGet(ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int32]*user.User, len(found))
+	for _, u := range found {
+		byID[u.Id] = u
+	}
+	users := make([]*user.User, len(ids))
+	for i, id := range ids {
+		users[i] = byID[id]
+	}
+	logging.NewLogger().Sugar().
+		With("function", "batchGet").
+		With("requested", len(ids)).
+		With("found", len(found)).
+		Info("returning results")
+	return users, nil
 }
 
-// Modify updates a users details based on the provided SearchOptions
-// The searchoptions must include either an email address or a nickname and country
-// Search terms must match exactly the entries in the existing user row.
-func (s *Service) Modify(userID int32, u *user.User) error {
+// Search returns a single page of the users matching the provided SearchOptions
+// a nil SearchOptions pages through all users
+// Pages are selected by keyset so remain consistent when users are added or removed between requests
+// Fuzzy searches are ordered by relevance unless the page is ordered otherwise.
+func (s *Service) Search(o *SearchOptions, p Page) (*Results, error) {
+	switch {
+	case p.OrderBy == "" && o.query() != "":
+		p.OrderBy = relevanceColumn
+	case p.OrderBy == relevanceColumn && o.query() == "":
+		return nil, ErrInvalidOrderBy
+	}
+	results, err := s.store.Search(o, p)
+	if err != nil {
+		return nil, err
+	}
+	logging.NewLogger().Sugar().
+		With("function", "search").
+		With("search", o.values()).
+		With("query", o.query()).
+		With("order_by", p.OrderBy).
+		With("results", len(results.Users)).
+		With("total", results.Total).
+		Info("returning results")
+	return results, nil
+}
+
+// Modify replaces the details of the user with the id.
+// An empty password leaves the stored password unchanged.
+// u.Etag must be the etag of the user as last read, otherwise ErrEtagRequired or ErrEtagMismatch is returned.
+// The user is returned as stored, or ErrNotFound if there is no user with the id
+func (s *Service) Modify(userID int32, u *user.User, a Audit) (*user.User, error) {
+	if u.Etag == "" {
+		return nil, ErrEtagRequired
+	}
+	if _, err := parseEtag(u.Etag); err != nil {
+		return nil, err
+	}
 	u.Id = userID
-	_, err := s.db.NamedExec(sqlModify, toInsert(u))
+	if u.Password != "" {
+		hashed, err := password.Hash(u.Password)
+		if err != nil {
+			return nil, err
+		}
+		u.Password = hashed
+	}
+	stored, err := s.store.Modify(u, a)
+	u.Password = ""
 	if err != nil {
-		logging.NewLogger().Sugar().With("error", err).Warn("error executing query")
-		return err
+		return nil, err
 	}
 	logging.NewLogger().Sugar().
 		With("function", "modify").
-		With("user", u).
+		With("user", stored).
 		Info("user updated")
-	return nil
+	return stored, nil
+}
+
+// Update sets only the fields of the user listed in fields, e.g. firstName or country, leaving the others unchanged
+// Unknown fields are rejected, and a listed password must not be empty.
+// If u.Etag is set the update only succeeds if it is still the etag of the user, otherwise ErrEtagMismatch is returned.
+// The user is returned as stored, or ErrNotFound if there is no user with the id
+func (s *Service) Update(userID int32, u *user.User, fields []string, a Audit) (*user.User, error) {
+	cols, err := columns(fields)
+	if err != nil {
+		return nil, err
+	}
+	if u.Etag != "" {
+		if _, err := parseEtag(u.Etag); err != nil {
+			return nil, err
+		}
+	}
+	u.Id = userID
+	for _, col := range cols {
+		if col != "password" {
+			continue
+		}
+		if u.Password == "" {
+			return nil, userio.InvalidArgumentError("EMPTY_PASSWORD", "password cannot be updated to an empty value")
+		}
+		hashed, err := password.Hash(u.Password)
+		if err != nil {
+			return nil, err
+		}
+		u.Password = hashed
+	}
+	stored, err := s.store.Update(u, cols, a)
+	u.Password = ""
+	if err != nil {
+		return nil, err
+	}
+	logging.NewLogger().Sugar().
+		With("function", "update").
+		With("fields", fields).
+		With("user", stored).
+		Info("user updated")
+	return stored, nil
 }
 
-// Delete deletes a users details based on the provided SearchOptions
-// The searchoptions must include either an email address or a nickname and country
-// Search terms must match exactly the entries in the existing user row.
-func (s *Service) Delete(userID int32) error {
-	_, err := s.db.Exec(sqlDelete, userID)
+// Delete deletes the user with the id.
+// The user is kept as deleted, and can be restored until it is purged.
+// tag must be the etag of the user as last read, otherwise ErrEtagRequired or ErrEtagMismatch is returned.
+// ErrNotFound is returned if there is no user with the id
+func (s *Service) Delete(userID int32, tag string, a Audit) error {
+	if tag == "" {
+		return ErrEtagRequired
+	}
+	version, err := parseEtag(tag)
 	if err != nil {
-		logging.NewLogger().Sugar().With("error", err).Warn("error executing query")
+		return err
+	}
+	if err := s.store.Delete(userID, version, a); err != nil {
 		return err
 	}
 	logging.NewLogger().Sugar().
@@ -111,3 +220,136 @@ func (s *Service) Delete(userID int32) error {
 		Info("user deleted")
 	return nil
 }
+
+// Restore undoes the deletion of a user, returning the user as stored
+// ErrNotDeleted is returned if there is no deleted user with the id
+func (s *Service) Restore(userID int32, a Audit) (*user.User, error) {
+	restored, err := s.store.Restore(userID, a)
+	if err != nil {
+		return nil, err
+	}
+	logging.NewLogger().Sugar().
+		With("function", "restore").
+		With("user", restored).
+		Info("user restored")
+	return restored, nil
+}
+
+// Purge permanently removes the users that were deleted more than retention ago,
+// returning how many were removed. The retention must be at least a day.
+func (s *Service) Purge(retention time.Duration, a Audit) (int, error) {
+	if retention < 24*time.Hour {
+		return 0, ErrInvalidRetention
+	}
+	before := time.Now().Add(-retention)
+	purged, err := s.store.Purge(before, a)
+	if err != nil {
+		return 0, err
+	}
+	logging.NewLogger().Sugar().
+		With("function", "purge").
+		With("before", before).
+		With("purged", purged).
+		Info("deleted users purged")
+	return purged, nil
+}
+
+// ListAuditEvents returns a single page of the changes made to a user, in the order they were made,
+// limited to those at or after start and before end unless they are zero. Only p.Size and p.Token are used.
+func (s *Service) ListAuditEvents(userID int32, start, end time.Time, p Page) (*AuditResults, error) {
+	results, err := s.store.AuditEvents(userID, start, end, p)
+	if err != nil {
+		return nil, err
+	}
+	logging.NewLogger().Sugar().
+		With("function", "listAuditEvents").
+		With("userID", userID).
+		With("results", len(results.Events)).
+		Info("returning audit events")
+	return results, nil
+}
+
+// VerifyPassword returns the user identified by login, which may be either their email or nickname,
+// provided that the password matches their stored password
+// ErrInvalidCredentials is returned for both unknown users and incorrect passwords
+func (s *Service) VerifyPassword(login, plaintext string) (*user.User, error) {
+	userID, hash, err := s.store.Credentials(login)
+	if err == ErrInvalidCredentials {
+		// Hash anyway so that unknown users take as long to reject as incorrect passwords
+		password.Hash(plaintext)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	ok, err := password.Verify(hash, plaintext)
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("userID", userID).
+			With("error", err).
+			Warn("stored password is not a valid hash")
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	u, err := s.GetByID(userID)
+	if err == ErrNotFound {
+		return nil, ErrInvalidCredentials
+	}
+	return u, err
+}
+
+// CreateWebhook subscribes the URL of the webhook to the events in its filter, or to every event if it has none.
+// A secret is generated if the webhook has none; it is set on w, and is not returned again.
+func (s *Service) CreateWebhook(w *user.Webhook) error {
+	if err := validateWebhook(w); err != nil {
+		return err
+	}
+	if err := s.store.AddWebhook(w); err != nil {
+		return err
+	}
+	logging.NewLogger().Sugar().
+		With("function", "createWebhook").
+		With("webhookID", w.Id).
+		With("url", w.Url).
+		With("events", w.Events).
+		Info("webhook created")
+	return nil
+}
+
+// ListWebhooks returns every webhook, without their secrets
+func (s *Service) ListWebhooks() ([]*user.Webhook, error) {
+	return s.store.Webhooks()
+}
+
+// DeleteWebhook removes a webhook, discarding its undelivered events
+// ErrWebhookNotFound is returned if there is no webhook with the id
+func (s *Service) DeleteWebhook(id int32) error {
+	if err := s.store.DeleteWebhook(id); err != nil {
+		return err
+	}
+	logging.NewLogger().Sugar().
+		With("function", "deleteWebhook").
+		With("webhookID", id).
+		Info("webhook deleted")
+	return nil
+}
+
+// ListDeadLetters returns a single page of the deliveries which failed every attempt, oldest first,
+// limited to those of the webhook unless webhookID is 0. Only p.Size and p.Token are used.
+func (s *Service) ListDeadLetters(webhookID int32, p Page) (*DeadLetters, error) {
+	return s.store.DeadLetters(webhookID, p)
+}
+
+// Replay attempts a dead letter again, with as many retries as a new delivery
+// ErrDeadLetterNotFound is returned if there is no dead letter with the id
+func (s *Service) Replay(id int64) error {
+	if err := s.store.Replay(id); err != nil {
+		return err
+	}
+	logging.NewLogger().Sugar().
+		With("function", "replay").
+		With("deliveryID", id).
+		Info("dead letter replayed")
+	return nil
+}
@@ -0,0 +1,1160 @@
+package userservice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/beldin0/users/src/logging"
+	"github.com/beldin0/users/src/user"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// dialect captures the differences between the databases supported by SQLStore
+type dialect struct {
+	// returning is whether inserts can return the new id using RETURNING
+	returning bool
+	// forUpdate locks the rows selected in a transaction, where supported
+	forUpdate string
+	// outboxLock is taken before writing to the outbox, so that events are committed in the order of their ids
+	// and watchers reading by id do not skip events which commit late. SQLite already has a single writer.
+	outboxLock string
+	// notify wakes the watchers of other replicas when the transaction commits
+	notify string
+	// duplicate is the text of the error raised when a unique constraint is violated
+	duplicate string
+	// copyIn is whether imports can be bulk loaded using COPY
+	copyIn bool
+	// cursor is whether exports are read from a server side cursor
+	cursor bool
+	// arrays is whether an array can be bound to a parameter, so that a query can select any number of ids
+	arrays bool
+	// trigram is whether fuzzy searches can use the pg_trgm % operator, and so its indexes. Otherwise the
+	// similarity of each user is compared, using the similarity function registered with the database driver.
+	trigram bool
+	// greatest is the function returning the greatest of its arguments
+	greatest string
+}
+
+var (
+	postgresDialect = dialect{
+		returning:  true,
+		forUpdate:  " FOR UPDATE",
+		outboxLock: `SELECT pg_advisory_xact_lock(7081997)`,
+		notify:     `SELECT pg_notify('` + notifyChannel + `', '')`,
+		duplicate:  "duplicate key value violates unique constraint",
+		copyIn:     true,
+		cursor:     true,
+		arrays:     true,
+		trigram:    true,
+		greatest:   "GREATEST",
+	}
+	sqliteDialect = dialect{
+		returning: false,
+		duplicate: "UNIQUE constraint failed",
+		greatest:  "MAX",
+	}
+)
+
+// relevance returns the expression of the similarity of a user to the fuzzy search text,
+// calculated as by SearchOptions.relevance
+func (d dialect) relevance(text string) (string, []interface{}) {
+	return d.greatest + `(similarity(` + sqlFullName + `, ?), similarity(nickname_lower, ?))`, []interface{}{text, text}
+}
+
+// similar returns the condition matching the users similar to the fuzzy search text
+func (d dialect) similar(text string) (string, []interface{}) {
+	if d.trigram {
+		return `(` + sqlFullName + ` % ? OR nickname_lower % ?)`, []interface{}{text, text}
+	}
+	relevance, args := d.relevance(text)
+	return relevance + ` >= ?`, append(args, similarityThreshold)
+}
+
+// notifyChannel is the Postgres channel notified of new events in the outbox
+const notifyChannel = "user_events"
+
+// SQLStore is a Store backed by a SQL database
+type SQLStore struct {
+	db      *sqlx.DB
+	dialect dialect
+	changes *broadcaster
+}
+
+// NewPostgresStore returns a Store utilising the provided Postgres database, which must already have been migrated
+func NewPostgresStore(db *sqlx.DB) *SQLStore {
+	return &SQLStore{
+		db:      db,
+		dialect: postgresDialect,
+		changes: newBroadcaster(),
+	}
+}
+
+// NewSQLiteStore returns a Store utilising the provided SQLite database, which must already have been migrated
+func NewSQLiteStore(db *sqlx.DB) *SQLStore {
+	return &SQLStore{
+		db:      db,
+		dialect: sqliteDialect,
+		changes: newBroadcaster(),
+	}
+}
+
+// Add inserts a new user, setting its Id, and records it in the audit log
+func (s *SQLStore) Add(u *user.User, a Audit) error {
+	err := s.inTx(func(tx *sqlx.Tx) error {
+		var err error
+		if s.dialect.returning {
+			err = insertReturning(tx, u)
+		} else {
+			err = insert(tx, u)
+		}
+		if err != nil {
+			return err
+		}
+		added, err := s.byID(tx, u.Id)
+		if err != nil {
+			return err
+		}
+		return s.record(tx, a, OpCreate, u.Id, added, diff(nil, added, true))
+	})
+	if err != nil {
+		logging.NewLogger().Sugar().With("error", err).Warn("error executing query")
+		return classify(err, s.dialect)
+	}
+	// new users start at the version column's default
+	u.Etag = etag(1)
+	return nil
+}
+
+func insertReturning(tx *sqlx.Tx, u *user.User) error {
+	rows, err := tx.NamedQuery(sqlInsert+` RETURNING id`, toInsert(u))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		var i int32
+		if err := rows.Scan(&i); err != nil {
+			return err
+		}
+		u.Id = i
+	}
+	return rows.Err()
+}
+
+func insert(tx *sqlx.Tx, u *user.User) error {
+	result, err := tx.NamedExec(sqlInsert, toInsert(u))
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	u.Id = int32(id)
+	return nil
+}
+
+// Import adds the users in a single transaction, returning the error adding each user.
+// Postgres loads the users using COPY, falling back to inserting them one at a time when a user
+// cannot be added, so that the users which can be added are.
+func (s *SQLStore) Import(users []*user.User, a Audit, dryRun bool) ([]error, error) {
+	errs := make([]error, len(users))
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, classify(err, s.dialect)
+	}
+	defer tx.Rollback()
+	copied := false
+	if s.dialect.copyIn {
+		if copied, err = copyUsers(tx, users); err != nil {
+			logging.NewLogger().Sugar().With("error", err).Warn("error copying users")
+			return nil, classify(err, s.dialect)
+		}
+	}
+	added := make([]*user.User, 0, len(users))
+	for i, u := range users {
+		if !copied {
+			if errs[i], err = s.insertRow(tx, u); err != nil {
+				logging.NewLogger().Sugar().With("error", err).Warn("error executing query")
+				return nil, classify(err, s.dialect)
+			}
+			if errs[i] != nil {
+				continue
+			}
+		}
+		u.Etag = etag(1)
+		added = append(added, u)
+	}
+	if err := s.recordCreated(tx, a, added); err != nil {
+		logging.NewLogger().Sugar().With("error", err).Warn("error recording imported users")
+		return nil, classify(err, s.dialect)
+	}
+	if dryRun {
+		return errs, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, classify(err, s.dialect)
+	}
+	s.changes.notify()
+	return errs, nil
+}
+
+// copyUsers loads the users using COPY and sets their ids, reporting whether they were all added.
+// If any user cannot be added, for instance as it is a duplicate, the transaction is returned to where it was.
+func copyUsers(tx *sqlx.Tx, users []*user.User) (bool, error) {
+	if _, err := tx.Exec(`SAVEPOINT import_copy`); err != nil {
+		return false, err
+	}
+	if err := copyIn(tx, users); err != nil {
+		_, rollbackErr := tx.Exec(`ROLLBACK TO SAVEPOINT import_copy`)
+		return false, rollbackErr
+	}
+	emails := make([]string, len(users))
+	for i, u := range users {
+		emails[i] = strings.ToLower(u.Email)
+	}
+	rows, err := tx.Query(tx.Rebind(sqlImportedIDs), pq.Array(emails))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	ids := map[string]int32{}
+	for rows.Next() {
+		var id int32
+		var email string
+		if err := rows.Scan(&id, &email); err != nil {
+			return false, err
+		}
+		ids[email] = id
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	for i, u := range users {
+		u.Id = ids[emails[i]]
+	}
+	_, err = tx.Exec(`RELEASE SAVEPOINT import_copy`)
+	return true, err
+}
+
+// copyIn writes the users to the users table using COPY
+func copyIn(tx *sqlx.Tx, users []*user.User) error {
+	stmt, err := tx.Prepare(pq.CopyIn("users", "first_name", "first_name_lower", "last_name", "last_name_lower",
+		"nickname", "nickname_lower", "password", "email", "country"))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, u := range users {
+		r := toInsert(u)
+		_, err := stmt.Exec(r.Firstname, r.FirstnameLower, r.Lastname, r.LastnameLower,
+			r.Nickname, r.NicknameLower, r.Password, r.Email, r.Country)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		return err
+	}
+	return stmt.Close()
+}
+
+// insertRow inserts a single imported user under a savepoint, so that the transaction can continue if it fails.
+// The first error is why the user could not be added, and the second is returned if the transaction failed.
+func (s *SQLStore) insertRow(tx *sqlx.Tx, u *user.User) (error, error) {
+	if _, err := tx.Exec(`SAVEPOINT import_row`); err != nil {
+		return nil, err
+	}
+	var err error
+	if s.dialect.returning {
+		err = insertReturning(tx, u)
+	} else {
+		err = insert(tx, u)
+	}
+	if err != nil {
+		if _, rollbackErr := tx.Exec(`ROLLBACK TO SAVEPOINT import_row`); rollbackErr != nil {
+			return nil, rollbackErr
+		}
+		if err := classify(err, s.dialect); errors.Is(err, ErrDuplicate) {
+			return err, nil
+		}
+		return nil, err
+	}
+	_, err = tx.Exec(`RELEASE SAVEPOINT import_row`)
+	return nil, err
+}
+
+// Get returns the users matching the SearchOptions
+// matches are made using LIKE so can be partial search terms
+func (s *SQLStore) Get(o *SearchOptions) ([]*user.User, error) {
+	where, args := o.where()
+	return s.query(sqlGet+where, args...)
+}
+
+// BatchGet returns the live users with any of the ids in a single query, binding the ids as an array
+// where supported, and otherwise as a parameter for each
+func (s *SQLStore) BatchGet(ids []int32) ([]*user.User, error) {
+	if len(ids) == 0 {
+		return []*user.User{}, nil
+	}
+	array := make([]int64, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		array[i] = int64(id)
+		args[i] = id
+	}
+	if s.dialect.arrays {
+		return s.query(sqlBatchGet, pq.Array(array))
+	}
+	placeholders := strings.Repeat("?, ", len(ids))
+	return s.query(sqlGet+` WHERE id IN (`+placeholders[:len(placeholders)-2]+`) AND deleted_at IS NULL`, args...)
+}
+
+// Search returns a single page of the users matching the SearchOptions
+// Pages are selected by keyset so remain consistent when users are added or removed between requests
+func (s *SQLStore) Search(o *SearchOptions, p Page) (*Results, error) {
+	size, column, after, err := p.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	conditions, args := o.conditions()
+	text := o.query()
+	if text != "" {
+		condition, similarArgs := s.dialect.similar(text)
+		conditions = append(conditions, condition)
+		args = append(args, similarArgs...)
+	}
+	var total int
+	if err := s.db.Get(&total, s.db.Rebind(sqlCount+whereClause(conditions)), args...); err != nil {
+		logging.NewLogger().Sugar().
+			With("query", sqlCount+whereClause(conditions)).
+			With("error", err).
+			Warn("error executing query")
+		return nil, classify(err, s.dialect)
+	}
+
+	var keyset []string
+	var keyArgs []interface{}
+	if after != nil {
+		condition, conditionArgs := after.keyset(column)
+		keyset, keyArgs = []string{condition}, conditionArgs
+	}
+	var query string
+	if text == "" {
+		query = sqlGet + whereClause(append(conditions, keyset...))
+	} else {
+		// fuzzy searches select the relevance in a subquery, so that the page can be keyed on it
+		relevance, relevanceArgs := s.dialect.relevance(text)
+		query = sqlRelevance + relevance + ` AS relevance FROM users` + whereClause(conditions) + `) AS matches` + whereClause(keyset)
+		args = append(relevanceArgs, args...)
+	}
+	args = append(args, keyArgs...)
+	switch column {
+	case "id":
+		query += ` ORDER BY "id"`
+	case relevanceColumn:
+		query += ` ORDER BY "relevance" DESC, "id"`
+	default:
+		query += ` ORDER BY "` + column + `", "id"`
+	}
+	query += " LIMIT ?"
+	args = append(args, size+1)
+
+	var users []*user.User
+	var scores []float32
+	if text == "" {
+		users, err = s.query(query, args...)
+	} else {
+		users, scores, err = s.queryRelevance(query, args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	results := &Results{Users: users, Total: total, Scores: scores}
+	if len(users) > size {
+		results.Users = users[:size]
+		var score float32
+		if scores != nil {
+			results.Scores = scores[:size]
+			score = scores[size-1]
+		}
+		results.NextToken = nextCursor(p.OrderBy, column, results.Users[size-1], score)
+	}
+	return results, nil
+}
+
+// query executes a query selecting sqlGet columns and scans the resulting users
+func (s *SQLStore) query(query string, args ...interface{}) ([]*user.User, error) {
+	query = s.db.Rebind(query)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("query", query).
+			With("error", err).
+			Warn("error executing query")
+		return nil, classify(err, s.dialect)
+	}
+	defer rows.Close()
+	results, err := scanUsers(rows, query)
+	return results, classify(err, s.dialect)
+}
+
+// queryRelevance executes a query selecting sqlRelevance columns and scans the resulting users and their relevance
+func (s *SQLStore) queryRelevance(query string, args ...interface{}) ([]*user.User, []float32, error) {
+	query = s.db.Rebind(query)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("query", query).
+			With("error", err).
+			Warn("error executing query")
+		return nil, nil, classify(err, s.dialect)
+	}
+	defer rows.Close()
+	users, scores := []*user.User{}, []float32{}
+	for rows.Next() {
+		var score float32
+		users = append(users, scanUser(rows, query, &score))
+		scores = append(scores, score)
+	}
+	return users, scores, classify(rows.Err(), s.dialect)
+}
+
+// scanUsers scans rows of sqlGet columns into users
+func scanUsers(rows *sql.Rows, query string) ([]*user.User, error) {
+	results := []*user.User{}
+	for rows.Next() {
+		results = append(results, scanUser(rows, query))
+	}
+	return results, rows.Err()
+}
+
+// scanUser scans the current row of sqlGet columns into a user, and any further columns into extra
+func scanUser(rows *sql.Rows, query string, extra ...interface{}) *user.User {
+	u := user.User{}
+	var version int64
+	var deletedAt sql.NullTime
+	dest := append([]interface{}{&u.Id, &u.FirstName, &u.LastName, &u.Nickname, &u.Email, &u.Country, &version, &deletedAt}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		logging.NewLogger().Sugar().
+			With("query", query).
+			With("error", err).
+			Warn("error processing rows query")
+	}
+	u.Etag = etag(version)
+	if deletedAt.Valid {
+		u.DeleteTime = deleteTime(&deletedAt.Time)
+	}
+	return &u
+}
+
+// Export calls f with each user matching the SearchOptions in order of id, stopping at the first error.
+// Postgres reads the users from a server side cursor, in a read only transaction so that the export is consistent,
+// and SQLite steps through the results, so that memory use does not grow with the number of users.
+func (s *SQLStore) Export(o *SearchOptions, f func(*user.User) error) error {
+	where, args := o.where()
+	query := s.db.Rebind(sqlGet + where + ` ORDER BY "id"`)
+	var err error
+	if s.dialect.cursor {
+		err = s.exportCursor(query, args, f)
+	} else {
+		err = s.exportRows(query, args, f)
+	}
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("query", query).
+			With("error", err).
+			Warn("error exporting users")
+	}
+	return classify(err, s.dialect)
+}
+
+// exportCursor calls f with each user selected by the query, fetching them from a cursor in batches
+func (s *SQLStore) exportCursor(query string, args []interface{}, f func(*user.User) error) error {
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DECLARE export_users NO SCROLL CURSOR FOR `+query, args...); err != nil {
+		return err
+	}
+	for {
+		rows, err := tx.Query(sqlFetchExport)
+		if err != nil {
+			return err
+		}
+		fetched := 0
+		for rows.Next() {
+			fetched++
+			if err := f(scanUser(rows, query)); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if fetched < exportBatch {
+			return nil
+		}
+	}
+}
+
+// exportRows calls f with each user selected by the query as the rows are read
+func (s *SQLStore) exportRows(query string, args []interface{}, f func(*user.User) error) error {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := f(scanUser(rows, query)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Modify replaces the details of the user with u.Id, returning the user as stored
+func (s *SQLStore) Modify(u *user.User, a Audit) (*user.User, error) {
+	return s.update(sqlModify, u, a, OpModify, notModified)
+}
+
+// Update sets only the named columns of the user with u.Id, returning the user as stored
+func (s *SQLStore) Update(u *user.User, columns []string, a Audit) (*user.User, error) {
+	return s.update(updateQuery(columns, u.Etag != ""), u, a, OpUpdate, notModified)
+}
+
+// Restore undoes the deletion of the user with the id, returning the user as stored
+func (s *SQLStore) Restore(userID int32, a Audit) (*user.User, error) {
+	return s.update(sqlRestore, &user.User{Id: userID}, a, OpRestore, func(sqlx.Ext, int32) error {
+		return ErrNotDeleted
+	})
+}
+
+// update executes an UPDATE statement with the named parameters of u and records the change in the audit log,
+// returning the user as stored. If no user is updated, missing returns the reason why.
+func (s *SQLStore) update(query string, u *user.User, a Audit, op string, missing func(sqlx.Ext, int32) error) (*user.User, error) {
+	var stored *user.User
+	err := s.inTx(func(tx *sqlx.Tx) error {
+		before, err := s.byID(tx, u.Id)
+		if err != nil {
+			return err
+		}
+		if s.dialect.returning {
+			stored, err = updateReturning(tx, query, u)
+		} else {
+			stored, err = s.updateThenGet(tx, query, u)
+		}
+		if err != nil {
+			return err
+		}
+		if stored == nil {
+			return missing(tx, u.Id)
+		}
+		return s.record(tx, a, op, u.Id, stored, diff(before, stored, u.Password != ""))
+	})
+	if err != nil {
+		var e *Error
+		if !errors.As(err, &e) {
+			logging.NewLogger().Sugar().
+				With("query", query).
+				With("error", err).
+				Warn("error executing query")
+		}
+		return nil, classify(err, s.dialect)
+	}
+	return stored, nil
+}
+
+// updateReturning updates the user, returning it as stored or nil if it was not updated
+func updateReturning(tx *sqlx.Tx, query string, u *user.User) (*user.User, error) {
+	rows, err := tx.NamedQuery(query+sqlReturning, toInsert(u))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users, err := scanUsers(rows.Rows, query)
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+	return users[0], nil
+}
+
+// updateThenGet updates the user and reads it back, for databases without RETURNING
+func (s *SQLStore) updateThenGet(tx *sqlx.Tx, query string, u *user.User) (*user.User, error) {
+	result, err := tx.NamedExec(query, toInsert(u))
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return nil, err
+	}
+	return s.byID(tx, u.Id)
+}
+
+// Delete marks the user with the id as deleted, provided that it is still at version
+func (s *SQLStore) Delete(userID int32, version int64, a Audit) error {
+	err := s.inTx(func(tx *sqlx.Tx) error {
+		before, err := s.byID(tx, userID)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec(tx.Rebind(sqlDelete), time.Now().UTC(), userID, version)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return notModified(tx, userID)
+		}
+		after, err := s.byID(tx, userID)
+		if err != nil {
+			return err
+		}
+		return s.record(tx, a, OpDelete, userID, after, diff(before, after, false))
+	})
+	var e *Error
+	if err != nil && !errors.As(err, &e) {
+		logging.NewLogger().Sugar().With("error", err).Warn("error executing query")
+	}
+	return classify(err, s.dialect)
+}
+
+// Purge permanently removes the users deleted before the time, returning how many were removed
+func (s *SQLStore) Purge(before time.Time, a Audit) (int, error) {
+	var ids []int32
+	err := s.inTx(func(tx *sqlx.Tx) error {
+		if s.dialect.returning {
+			if err := tx.Select(&ids, tx.Rebind(sqlPurge+` RETURNING id`), before.UTC()); err != nil {
+				return err
+			}
+		} else {
+			if err := tx.Select(&ids, tx.Rebind(sqlPurgeable), before.UTC()); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(tx.Rebind(sqlPurge), before.UTC()); err != nil {
+				return err
+			}
+		}
+		batch := make([]recordedChange, len(ids))
+		for i, id := range ids {
+			batch[i] = recordedChange{userID: id, changes: []change{}}
+		}
+		return s.recordMany(tx, a, OpPurge, batch)
+	})
+	if err != nil {
+		logging.NewLogger().Sugar().With("error", err).Warn("error executing query")
+		return 0, classify(err, s.dialect)
+	}
+	return len(ids), nil
+}
+
+// AuditEvents returns a single page of the audit events of the user, optionally limited to
+// those at or after start and before end
+func (s *SQLStore) AuditEvents(userID int32, start, end time.Time, p Page) (*AuditResults, error) {
+	size, err := p.size()
+	if err != nil {
+		return nil, err
+	}
+	after, err := tokenID(p.Token)
+	if err != nil {
+		return nil, err
+	}
+	query := sqlAuditEvents + ` WHERE user_id=? AND id>?`
+	args := []interface{}{userID, after}
+	if !start.IsZero() {
+		query += ` AND created_at>=?`
+		args = append(args, start.UTC())
+	}
+	if !end.IsZero() {
+		query += ` AND created_at<?`
+		args = append(args, end.UTC())
+	}
+	query += ` ORDER BY id LIMIT ?`
+	args = append(args, size+1)
+
+	records := []auditRecord{}
+	if err := s.db.Select(&records, s.db.Rebind(query), args...); err != nil {
+		logging.NewLogger().Sugar().
+			With("query", query).
+			With("error", err).
+			Warn("error executing query")
+		return nil, classify(err, s.dialect)
+	}
+	results, err := auditResults(records, size)
+	return results, classify(err, s.dialect)
+}
+
+// Unpublished returns up to limit events from the outbox which have not been published, in the order they were written,
+// other than the events of the users in skip
+func (s *SQLStore) Unpublished(limit int, skip []int32) ([]*user.UserEvent, error) {
+	query, args := sqlUnpublished, []interface{}{limit}
+	if len(skip) > 0 {
+		var err error
+		if query, args, err = sqlx.In(sqlUnpublishedSkipping, skip, limit); err != nil {
+			return nil, classify(err, s.dialect)
+		}
+	}
+	records := []outboxRecord{}
+	if err := s.db.Select(&records, s.db.Rebind(query), args...); err != nil {
+		logging.NewLogger().Sugar().
+			With("query", query).
+			With("error", err).
+			Warn("error executing query")
+		return nil, classify(err, s.dialect)
+	}
+	events, err := toEvents(records)
+	return events, classify(err, s.dialect)
+}
+
+// MarkPublished records that the events with the ids have been published
+func (s *SQLStore) MarkPublished(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In(sqlMarkPublished, time.Now().UTC(), ids)
+	if err == nil {
+		_, err = s.db.Exec(s.db.Rebind(query), args...)
+	}
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("query", sqlMarkPublished).
+			With("error", err).
+			Warn("error executing query")
+	}
+	return classify(err, s.dialect)
+}
+
+// Events returns up to limit events from the outbox written after the event with the id, in the order they were written
+func (s *SQLStore) Events(after int64, limit int) ([]*user.UserEvent, error) {
+	records := []outboxRecord{}
+	if err := s.db.Select(&records, s.db.Rebind(sqlEvents), after, limit); err != nil {
+		logging.NewLogger().Sugar().
+			With("query", sqlEvents).
+			With("error", err).
+			Warn("error executing query")
+		return nil, classify(err, s.dialect)
+	}
+	events, err := toEvents(records)
+	return events, classify(err, s.dialect)
+}
+
+// LastEventID returns the id of the latest event in the outbox, or 0 if there are none
+func (s *SQLStore) LastEventID() (int64, error) {
+	var id int64
+	if err := s.db.Get(&id, sqlLastEventID); err != nil {
+		logging.NewLogger().Sugar().
+			With("query", sqlLastEventID).
+			With("error", err).
+			Warn("error executing query")
+		return 0, classify(err, s.dialect)
+	}
+	return id, nil
+}
+
+// Changed returns a channel which is closed when the next event is written to the outbox,
+// by this process or, once Listen is running, by any replica sharing a Postgres database
+func (s *SQLStore) Changed() <-chan struct{} {
+	return s.changes.wait()
+}
+
+// Listen wakes watchers when other replicas write to the outbox of the Postgres database at connString,
+// until ctx is done. The connection is reopened if it is lost.
+func (s *SQLStore) Listen(ctx context.Context, connString string) error {
+	listener := pq.NewListener(connString, time.Second, time.Minute, func(_ pq.ListenerEventType, err error) {
+		if err != nil {
+			logging.NewLogger().Sugar().With("error", err).Warn("error listening for events")
+		}
+	})
+	defer listener.Close()
+	if err := listener.Listen(notifyChannel); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-listener.Notify:
+			// a nil notification after reconnecting may follow missed events, so it wakes watchers too
+			s.changes.notify()
+		}
+	}
+}
+
+// AddWebhook inserts a new webhook, setting its Id and CreateTime
+func (s *SQLStore) AddWebhook(w *user.Webhook) error {
+	record := newWebhookRecord(w)
+	err := s.inTx(func(tx *sqlx.Tx) error {
+		id, err := s.insertID(tx, sqlInsertWebhook, record)
+		record.ID = int32(id)
+		return err
+	})
+	if err != nil {
+		logging.NewLogger().Sugar().With("error", err).Warn("error executing query")
+		return classify(err, s.dialect)
+	}
+	w.Id = record.ID
+	w.CreateTime = record.toWebhook().CreateTime
+	return nil
+}
+
+// Webhooks returns every webhook, without their secrets
+func (s *SQLStore) Webhooks() ([]*user.Webhook, error) {
+	records := []webhookRecord{}
+	if err := s.db.Select(&records, sqlWebhooks+` ORDER BY id`); err != nil {
+		logging.NewLogger().Sugar().
+			With("query", sqlWebhooks).
+			With("error", err).
+			Warn("error executing query")
+		return nil, classify(err, s.dialect)
+	}
+	webhooks := make([]*user.Webhook, len(records))
+	for i, r := range records {
+		webhooks[i] = r.toWebhook()
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes the webhook with the id along with its deliveries
+func (s *SQLStore) DeleteWebhook(id int32) error {
+	err := s.inTx(func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(tx.Rebind(sqlDeleteDeliveries), id); err != nil {
+			return err
+		}
+		result, err := tx.Exec(tx.Rebind(sqlDeleteWebhook), id)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err == nil && n == 0 {
+			err = ErrWebhookNotFound
+		}
+		return err
+	})
+	var e *Error
+	if err != nil && !errors.As(err, &e) {
+		logging.NewLogger().Sugar().With("error", err).Warn("error executing query")
+	}
+	return classify(err, s.dialect)
+}
+
+// DueDeliveries returns up to limit pending deliveries due to be attempted at now, oldest first
+func (s *SQLStore) DueDeliveries(now time.Time, limit int) ([]Delivery, error) {
+	records := []deliveryRecord{}
+	if err := s.db.Select(&records, s.db.Rebind(sqlDueDeliveries), DeliveryPending, now.UTC(), limit); err != nil {
+		logging.NewLogger().Sugar().
+			With("query", sqlDueDeliveries).
+			With("error", err).
+			Warn("error executing query")
+		return nil, classify(err, s.dialect)
+	}
+	deliveries := make([]Delivery, len(records))
+	for i, r := range records {
+		d, err := r.toDelivery()
+		if err != nil {
+			return nil, classify(err, s.dialect)
+		}
+		deliveries[i] = d
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt records the outcome of an attempt to make a delivery
+func (s *SQLStore) RecordAttempt(id int64, state string, attempts int, lastError string, next time.Time) error {
+	_, err := s.db.Exec(s.db.Rebind(sqlRecordAttempt), state, attempts, lastError, next.UTC(), id)
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("query", sqlRecordAttempt).
+			With("error", err).
+			Warn("error executing query")
+	}
+	return classify(err, s.dialect)
+}
+
+// DeadLetters returns a single page of the deliveries which failed every attempt, oldest first
+func (s *SQLStore) DeadLetters(webhookID int32, p Page) (*DeadLetters, error) {
+	size, err := p.size()
+	if err != nil {
+		return nil, err
+	}
+	after, err := tokenID(p.Token)
+	if err != nil {
+		return nil, err
+	}
+	query := sqlDeadLetters
+	args := []interface{}{DeliveryDead, after}
+	if webhookID != 0 {
+		query += ` AND d.webhook_id=?`
+		args = append(args, webhookID)
+	}
+	query += ` ORDER BY d.id LIMIT ?`
+	args = append(args, size+1)
+
+	records := []deliveryRecord{}
+	if err := s.db.Select(&records, s.db.Rebind(query), args...); err != nil {
+		logging.NewLogger().Sugar().
+			With("query", query).
+			With("error", err).
+			Warn("error executing query")
+		return nil, classify(err, s.dialect)
+	}
+	return deadLetters(records, size), nil
+}
+
+// Replay returns a dead letter to pending, to be attempted straight away with no failed attempts
+func (s *SQLStore) Replay(id int64) error {
+	result, err := s.db.Exec(s.db.Rebind(sqlReplay), DeliveryPending, time.Now().UTC(), id, DeliveryDead)
+	if err != nil {
+		logging.NewLogger().Sugar().
+			With("query", sqlReplay).
+			With("error", err).
+			Warn("error executing query")
+		return classify(err, s.dialect)
+	}
+	n, err := result.RowsAffected()
+	if err == nil && n == 0 {
+		return ErrDeadLetterNotFound
+	}
+	return classify(err, s.dialect)
+}
+
+// inTx runs f in a transaction, which is committed if f succeeds
+func (s *SQLStore) inTx(f func(*sqlx.Tx) error) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.changes.notify()
+	return nil
+}
+
+// byID returns the user with the id, including deleted users, or nil if there is no such user.
+// The row is locked until the end of the transaction where the database supports it.
+func (s *SQLStore) byID(tx *sqlx.Tx, userID int32) (*user.User, error) {
+	rows, err := tx.Query(tx.Rebind(sqlGet+` WHERE id=?`+s.dialect.forUpdate), userID)
+	if err != nil {
+		return nil, err
+	}
+	users, err := scanUsers(rows, sqlGet)
+	rows.Close()
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+	return users[0], nil
+}
+
+// record records a change to a user in the audit log, and writes the event it publishes to the outbox,
+// in the transaction making the change. stored is the user after the change.
+// As the user's row is locked by the change, the events of a user are committed in the order of their ids.
+func (s *SQLStore) record(tx *sqlx.Tx, a Audit, op string, userID int32, stored *user.User, changes []change) error {
+	audit, err := newAuditRecord(a, op, userID, changes)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.NamedExec(sqlInsertAudit, audit); err != nil {
+		return err
+	}
+	event, err := newOutboxRecord(op, userID, stored, changes)
+	if err != nil || event == nil {
+		return err
+	}
+	if s.dialect.outboxLock != "" {
+		if _, err := tx.Exec(s.dialect.outboxLock); err != nil {
+			return err
+		}
+	}
+	eventID, err := s.insertID(tx, sqlInsertOutbox, event)
+	if err != nil {
+		return err
+	}
+	webhooks := []webhookRecord{}
+	if err := tx.Select(&webhooks, sqlWebhooks); err != nil {
+		return err
+	}
+	for _, w := range webhooks {
+		if !w.wants(event.EventType) {
+			continue
+		}
+		if _, err := tx.NamedExec(sqlInsertDelivery, newDeliveryRecord(w.ID, eventID)); err != nil {
+			return err
+		}
+	}
+	if s.dialect.notify != "" {
+		_, err = tx.Exec(s.dialect.notify)
+	}
+	return err
+}
+
+// recordCreated records the creation of a batch of users, as by recordMany
+func (s *SQLStore) recordCreated(tx *sqlx.Tx, a Audit, users []*user.User) error {
+	batch := make([]recordedChange, len(users))
+	for i, u := range users {
+		record := toInsert(u)
+		record.Version = 1
+		stored := record.toUser(u.Id)
+		batch[i] = recordedChange{userID: u.Id, stored: stored, changes: diff(nil, stored, true)}
+	}
+	return s.recordMany(tx, a, OpCreate, batch)
+}
+
+// recordMany records the changes to a batch of users like record, but in a few statements rather than several
+// for each user: the webhooks are read once, and the audit, outbox and delivery rows are written by multi-row INSERTs
+func (s *SQLStore) recordMany(tx *sqlx.Tx, a Audit, op string, batch []recordedChange) error {
+	audits := make([][]interface{}, 0, len(batch))
+	events := make([][]interface{}, 0, len(batch))
+	for _, c := range batch {
+		audit, err := newAuditRecord(a, op, c.userID, c.changes)
+		if err != nil {
+			return err
+		}
+		audits = append(audits, []interface{}{audit.UserID, audit.Actor, audit.Operation, audit.Changes,
+			audit.RequestID, audit.CreatedAt})
+		event, err := newOutboxRecord(op, c.userID, c.stored, c.changes)
+		if err != nil {
+			return err
+		}
+		if event != nil {
+			events = append(events, []interface{}{event.UserID, event.EventType, event.Payload, event.CreatedAt})
+		}
+	}
+	if err := insertMany(tx, sqlInsertAuditValues, audits); err != nil || len(events) == 0 {
+		return err
+	}
+	if s.dialect.outboxLock != "" {
+		if _, err := tx.Exec(s.dialect.outboxLock); err != nil {
+			return err
+		}
+	}
+	// the outbox lock (or SQLite's single writer) means that the events written next are the only ones after last
+	var last int64
+	if err := tx.Get(&last, sqlLastEventID); err != nil {
+		return err
+	}
+	if err := insertMany(tx, sqlInsertOutboxValues, events); err != nil {
+		return err
+	}
+	webhooks := []webhookRecord{}
+	if err := tx.Select(&webhooks, sqlWebhooks); err != nil {
+		return err
+	}
+	if len(webhooks) > 0 {
+		written := []outboxRecord{}
+		if err := tx.Select(&written, tx.Rebind(sqlEvents), last, len(events)); err != nil {
+			return err
+		}
+		deliveries := [][]interface{}{}
+		for _, e := range written {
+			for _, w := range webhooks {
+				if w.wants(e.EventType) {
+					d := newDeliveryRecord(w.ID, e.ID)
+					deliveries = append(deliveries, []interface{}{d.WebhookID, d.EventID, d.State, d.Attempts,
+						d.LastError, d.NextAttemptAt, d.CreatedAt})
+				}
+			}
+		}
+		if err := insertMany(tx, sqlInsertDeliveryValues, deliveries); err != nil {
+			return err
+		}
+	}
+	if s.dialect.notify != "" {
+		_, err := tx.Exec(s.dialect.notify)
+		return err
+	}
+	return nil
+}
+
+// insertMany completes an INSERT ending in VALUES with a list of parameters for each row, executing as few
+// statements as maxParams allows
+func insertMany(tx *sqlx.Tx, insert string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	width := len(rows[0])
+	row := "(?" + strings.Repeat(", ?", width-1) + ")"
+	for len(rows) > 0 {
+		n := maxParams / width
+		if n > len(rows) {
+			n = len(rows)
+		}
+		values := make([]string, n)
+		args := make([]interface{}, 0, n*width)
+		for i, r := range rows[:n] {
+			values[i] = row
+			args = append(args, r...)
+		}
+		if _, err := tx.Exec(tx.Rebind(insert+strings.Join(values, ", ")), args...); err != nil {
+			return err
+		}
+		rows = rows[n:]
+	}
+	return nil
+}
+
+// insertID executes a named INSERT, returning the id of the new row
+func (s *SQLStore) insertID(tx *sqlx.Tx, query string, arg interface{}) (int64, error) {
+	if !s.dialect.returning {
+		result, err := tx.NamedExec(query, arg)
+		if err != nil {
+			return 0, err
+		}
+		return result.LastInsertId()
+	}
+	rows, err := tx.NamedQuery(query+` RETURNING id`, arg)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var id int64
+	if rows.Next() {
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+	}
+	return id, rows.Err()
+}
+
+// notModified returns the reason that a user was not modified:
+// ErrNotFound if it does not exist, or ErrEtagMismatch if it is at a different version
+func notModified(q sqlx.Ext, userID int32) error {
+	var version int64
+	err := sqlx.Get(q, &version, q.Rebind(sqlVersion), userID)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return ErrEtagMismatch
+}
+
+// Credentials returns the id and password hash of the user with the email or nickname login
+func (s *SQLStore) Credentials(login string) (int32, string, error) {
+	var credentials struct {
+		ID   int32  `db:"id"`
+		Hash string `db:"password"`
+	}
+	login = strings.ToLower(login)
+	err := s.db.Get(&credentials, s.db.Rebind(sqlCredentials), login, login)
+	if err == sql.ErrNoRows {
+		return 0, "", ErrInvalidCredentials
+	}
+	if err != nil {
+		logging.NewLogger().Sugar().With("error", err).Warn("error executing query")
+		return 0, "", classify(err, s.dialect)
+	}
+	return credentials.ID, credentials.Hash, nil
+}
@@ -0,0 +1,84 @@
+package userservice
+
+import (
+	"time"
+
+	"github.com/beldin0/users/src/user"
+)
+
+// Store persists users on behalf of a Service.
+// Passwords passed to and returned from a Store are always hashes.
+// Every mutation is recorded in the audit log, attributed to the Audit, in the same transaction as the change.
+// The same transaction writes the UserEvent it publishes, if any, to the outbox to be published by a Relay.
+type Store interface {
+	// Add inserts a new user, setting its Id
+	// ErrDuplicate is returned if the email or nickname is already in use
+	Add(u *user.User, a Audit) error
+	// Import adds each of the users as by Add in a single transaction, returning the error adding each user,
+	// nil for those that were added. A dry run rolls the transaction back, so that no user is stored.
+	// An error is only returned if the transaction fails, in which case none of the users were added.
+	Import(users []*user.User, a Audit, dryRun bool) ([]error, error)
+	// Get returns the users matching the SearchOptions, or all live users if it is nil
+	Get(o *SearchOptions) ([]*user.User, error)
+	// BatchGet returns the live users with any of the ids, in any order, in a single query
+	BatchGet(ids []int32) ([]*user.User, error)
+	// Search returns a single page of the users matching the SearchOptions
+	Search(o *SearchOptions, p Page) (*Results, error)
+	// Export calls f with each user matching the SearchOptions in order of id, stopping at the first error,
+	// which is returned. Users are read as they are exported, so that memory use does not grow with their number.
+	Export(o *SearchOptions, f func(*user.User) error) error
+	// Modify replaces the details of the user with u.Id, keeping the stored password if u.Password is empty,
+	// and returns the user as stored with a new etag
+	// ErrNotFound is returned if there is no such user, and ErrEtagMismatch if u.Etag is not its current etag
+	Modify(u *user.User, a Audit) (*user.User, error)
+	// Update sets only the named columns of the user with u.Id to their values in u, and returns the user as stored
+	// with a new etag. u.Etag is only checked if it is set.
+	// ErrNotFound is returned if there is no such user, and ErrEtagMismatch if u.Etag is not its current etag
+	Update(u *user.User, columns []string, a Audit) (*user.User, error)
+	// Delete marks the user with the id as deleted, provided that it is still at version.
+	// Deleted users are excluded from every other method until they are restored.
+	// ErrNotFound is returned if there is no such user, and ErrEtagMismatch if it is at a different version
+	Delete(userID int32, version int64, a Audit) error
+	// Restore undoes the deletion of the user with the id, and returns the user as stored with a new etag
+	// ErrNotDeleted is returned if there is no deleted user with the id, and ErrDuplicate
+	// if its email or nickname has since been taken by another user
+	Restore(userID int32, a Audit) (*user.User, error)
+	// Purge permanently removes the users deleted before the time, returning how many were removed
+	Purge(before time.Time, a Audit) (int, error)
+	// AuditEvents returns a single page of the audit events of the user in the order they happened,
+	// limited to those at or after start and before end unless they are zero
+	AuditEvents(userID int32, start, end time.Time, p Page) (*AuditResults, error)
+	// Unpublished returns up to limit events from the outbox which have not been published, in the order they were written,
+	// other than the events of the users in skip
+	Unpublished(limit int, skip []int32) ([]*user.UserEvent, error)
+	// MarkPublished records that the events with the ids have been published
+	MarkPublished(ids []int64) error
+	// Events returns up to limit events from the outbox written after the event with the id, in the order they were written
+	Events(after int64, limit int) ([]*user.UserEvent, error)
+	// LastEventID returns the id of the latest event in the outbox, or 0 if there are none
+	LastEventID() (int64, error)
+	// Changed returns a channel which is closed when the next event is written to the outbox
+	Changed() <-chan struct{}
+	// AddWebhook inserts a new webhook, setting its Id and CreateTime.
+	// Every later event matching its filter is queued for delivery to it, in the transaction writing the event.
+	AddWebhook(w *user.Webhook) error
+	// Webhooks returns every webhook, without their secrets
+	Webhooks() ([]*user.Webhook, error)
+	// DeleteWebhook removes the webhook with the id along with its deliveries
+	// ErrWebhookNotFound is returned if there is no such webhook
+	DeleteWebhook(id int32) error
+	// DueDeliveries returns up to limit pending deliveries due to be attempted at now, oldest first
+	DueDeliveries(now time.Time, limit int) ([]Delivery, error)
+	// RecordAttempt records the outcome of an attempt to make a delivery: its state and number of failed attempts,
+	// why the last attempt failed, and when to next attempt it if it is still pending
+	RecordAttempt(id int64, state string, attempts int, lastError string, next time.Time) error
+	// DeadLetters returns a single page of the deliveries which failed every attempt, oldest first,
+	// limited to those of the webhook unless webhookID is 0. Only p.Size and p.Token are used.
+	DeadLetters(webhookID int32, p Page) (*DeadLetters, error)
+	// Replay returns a dead letter to pending, to be attempted straight away with no failed attempts
+	// ErrDeadLetterNotFound is returned if there is no dead letter with the id
+	Replay(id int64) error
+	// Credentials returns the id and password hash of the user with the email or nickname login
+	// ErrInvalidCredentials is returned if there is no such user
+	Credentials(login string) (int32, string, error)
+}
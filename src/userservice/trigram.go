@@ -0,0 +1,53 @@
+package userservice
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// similarityThreshold is the least similarity of a fuzzy match, the default pg_trgm.similarity_threshold
+// used by the Postgres % operator
+const similarityThreshold = 0.3
+
+// Similarity returns the similarity of two strings as pg_trgm calculates it: the number of trigrams they share
+// divided by the number of distinct trigrams in either. It is the fuzzy search of stores other than Postgres.
+func Similarity(a, b string) float32 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float32(shared) / float32(len(ta)+len(tb)-shared)
+}
+
+// trigrams returns the distinct trigrams of the lowercase words of s, which are split on any character
+// other than letters and digits and padded with two spaces before and one after, as in pg_trgm
+func trigrams(s string) map[string]bool {
+	t := map[string]bool{}
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		r := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			t[string(r[i:i+3])] = true
+		}
+	}
+	return t
+}
+
+// formatScore encodes a relevance score in a page token, exactly as the float4 which Postgres compares it with
+func formatScore(score float32) string {
+	return strconv.FormatFloat(float64(score), 'g', -1, 32)
+}
+
+func parseScore(s string) float32 {
+	score, _ := strconv.ParseFloat(s, 32)
+	return float32(score)
+}
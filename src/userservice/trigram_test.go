@@ -0,0 +1,20 @@
+package userservice
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimilarity(t *testing.T) {
+	// the values returned by pg_trgm for the same strings
+	require.Equal(t, float32(0.3125), Similarity("john smith", "Jonh Smth"))
+	require.Equal(t, float32(1), Similarity("John Smith", "john  SMITH!"))
+	require.Equal(t, float32(0), Similarity("alan", "grace"))
+	require.Equal(t, float32(0), Similarity("", "grace"))
+}
+
+func TestScoreEncoding(t *testing.T) {
+	score := float32(1) / 3
+	require.Equal(t, score, parseScore(formatScore(score)))
+}
@@ -0,0 +1,85 @@
+package userservice
+
+import (
+	"strings"
+
+	"github.com/beldin0/users/src/userio"
+)
+
+// updateColumns maps the fields a partial update can list to the columns they are stored in.
+// Text fields that are searched on also update their lowercase columns.
+var updateColumns = map[string][]string{
+	"firstName":  {"first_name", "first_name_lower"},
+	"first_name": {"first_name", "first_name_lower"},
+	"lastName":   {"last_name", "last_name_lower"},
+	"last_name":  {"last_name", "last_name_lower"},
+	"nickname":   {"nickname", "nickname_lower"},
+	"password":   {"password"},
+	"email":      {"email"},
+	"country":    {"country"},
+}
+
+// columns returns the columns to update for the fields of a FieldMask, without duplicates.
+// Unknown fields are rejected with an INVALID_UPDATE_MASK error.
+func columns(fields []string) ([]string, error) {
+	seen := map[string]bool{}
+	cols := []string{}
+	for _, f := range fields {
+		if f == "etag" {
+			// the etag is a precondition of the update rather than a field to change
+			continue
+		}
+		c, ok := updateColumns[f]
+		if !ok {
+			return nil, userio.InvalidArgumentError("INVALID_UPDATE_MASK", "field "+f+" cannot be updated")
+		}
+		for _, col := range c {
+			if !seen[col] {
+				seen[col] = true
+				cols = append(cols, col)
+			}
+		}
+	}
+	if len(cols) == 0 {
+		return nil, userio.InvalidArgumentError("INVALID_UPDATE_MASK", "update mask must list at least one field")
+	}
+	return cols, nil
+}
+
+// updateQuery returns an UPDATE statement setting only the columns, using the named parameters of insertUser
+// The user's version is always incremented, and is checked first if checkVersion is set.
+func updateQuery(cols []string, checkVersion bool) string {
+	set := make([]string, len(cols))
+	for i, col := range cols {
+		set[i] = col + "=:" + col
+	}
+	query := sqlUpdate + strings.Join(set, ", ") + `, version=version+1 WHERE id=:id AND deleted_at IS NULL`
+	if checkVersion {
+		query += ` AND version=:version`
+	}
+	return query
+}
+
+// assign sets the named column of the record to its value in from
+func (i *insertUser) assign(column string, from insertUser) {
+	switch column {
+	case "first_name":
+		i.Firstname = from.Firstname
+	case "first_name_lower":
+		i.FirstnameLower = from.FirstnameLower
+	case "last_name":
+		i.Lastname = from.Lastname
+	case "last_name_lower":
+		i.LastnameLower = from.LastnameLower
+	case "nickname":
+		i.Nickname = from.Nickname
+	case "nickname_lower":
+		i.NicknameLower = from.NicknameLower
+	case "password":
+		i.Password = from.Password
+	case "email":
+		i.Email = from.Email
+	case "country":
+		i.Country = from.Country
+	}
+}
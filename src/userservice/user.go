@@ -2,11 +2,18 @@ package userservice
 
 import (
 	"strings"
+	"time"
 
+	"github.com/beldin0/users/src/country"
 	"github.com/beldin0/users/src/user"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
 )
 
+// toInsert returns the columns of the user, with the version of its etag
+// The etag must already have been validated, as a malformed etag is treated as no version.
 func toInsert(u *user.User) insertUser {
+	version, _ := parseEtag(u.Etag)
 	return insertUser{
 		UserID:         &u.Id,
 		Firstname:      u.FirstName,
@@ -17,7 +24,8 @@ func toInsert(u *user.User) insertUser {
 		NicknameLower:  strings.ToLower(u.Nickname),
 		Password:       u.Password,
 		Email:          strings.ToLower(u.Email),
-		Country:        strings.ToUpper(u.Country),
+		Country:        country.Canonical(u.Country),
+		Version:        version,
 	}
 }
 
@@ -32,4 +40,19 @@ type insertUser struct {
 	Password       string `db:"password"`
 	Email          string `db:"email"`
 	Country        string `db:"country"`
+	Version        int64  `db:"version"`
+	// DeletedAt is only held by the MemoryStore, the database stores set it when deleting a user
+	DeletedAt *time.Time `db:"-"`
+}
+
+// deleteTime returns the time a user was deleted as a proto timestamp, nil if it is not deleted
+func deleteTime(deletedAt *time.Time) *timestamp.Timestamp {
+	if deletedAt == nil {
+		return nil
+	}
+	ts, err := ptypes.TimestampProto(*deletedAt)
+	if err != nil {
+		return nil
+	}
+	return ts
 }
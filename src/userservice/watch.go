@@ -0,0 +1,66 @@
+package userservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/beldin0/users/src/logging"
+	"github.com/beldin0/users/src/user"
+)
+
+const (
+	// watchBatch is the number of events read from the outbox at a time
+	watchBatch = 100
+	// watchPoll is how often watchers check for events when they have not been woken,
+	// in case a notification was missed
+	watchPoll = 5 * time.Second
+)
+
+// Watcher follows the events written to the outbox
+type Watcher struct {
+	store Store
+	after int64
+}
+
+// Watch returns a Watcher following the events written after the event with the resume token.
+// An empty token follows only the changes made after Watch is called.
+// ErrInvalidResumeToken is returned if the token is malformed.
+func (s *Service) Watch(token string) (*Watcher, error) {
+	after, err := tokenID(token)
+	if err != nil {
+		return nil, ErrInvalidResumeToken
+	}
+	if token == "" {
+		if after, err = s.store.LastEventID(); err != nil {
+			return nil, err
+		}
+	}
+	logging.NewLogger().Sugar().
+		With("function", "watch").
+		With("after", after).
+		Info("watching users")
+	return &Watcher{store: s.store, after: after}, nil
+}
+
+// Next waits for the events following those already returned, and returns them in order.
+// ctx.Err() is returned if ctx is done first.
+func (w *Watcher) Next(ctx context.Context) ([]*user.UserEvent, error) {
+	for {
+		// wait for changes from before reading, so that none are missed in between
+		changed := w.store.Changed()
+		events, err := w.store.Events(w.after, watchBatch)
+		if err != nil {
+			return nil, err
+		}
+		if len(events) > 0 {
+			w.after = events[len(events)-1].Id
+			return events, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		case <-time.After(watchPoll):
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package userservice
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beldin0/users/src/user"
+	"github.com/beldin0/users/src/userio"
+	"github.com/golang/protobuf/ptypes"
+)
+
+// The states of a webhook delivery
+const (
+	DeliveryPending   = "PENDING"
+	DeliveryDelivered = "DELIVERED"
+	// DeliveryDead deliveries failed every attempt, and are only attempted again when replayed
+	DeliveryDead = "DEAD"
+)
+
+// Delivery is an event due to be delivered to a webhook
+type Delivery struct {
+	ID        int64
+	WebhookID int32
+	URL       string
+	Secret    string
+	// Attempts is the number of earlier attempts, which failed
+	Attempts int
+	Event    *user.UserEvent
+}
+
+// DeadLetters is a single page of the deliveries which failed every attempt
+type DeadLetters struct {
+	Deliveries []*user.WebhookDelivery
+	// NextToken requests the following page, and is empty on the last page
+	NextToken string
+}
+
+// Sign returns the signature of a webhook delivery, sent in the X-Webhook-Signature header as sha256=<signature>.
+// It is the hex encoded HMAC-SHA256 of the X-Webhook-Timestamp header, a full stop and the body, keyed by the
+// secret of the webhook; receivers should also reject old timestamps, so that deliveries cannot be replayed.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validateWebhook checks the URL and event filter of a new webhook, and generates its secret if it has none
+func validateWebhook(w *user.Webhook) error {
+	u, err := url.Parse(w.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return userio.InvalidArgumentError("INVALID_WEBHOOK_URL", "url must be an absolute http or https URL")
+	}
+	for _, e := range w.Events {
+		if e != EventUserCreated && e != EventUserUpdated && e != EventUserDeleted {
+			return userio.InvalidArgumentError("INVALID_WEBHOOK_EVENT", "events must be UserCreated, UserUpdated or UserDeleted")
+		}
+	}
+	if w.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		w.Secret = hex.EncodeToString(secret)
+	}
+	return nil
+}
+
+// webhookRecord is a row of the webhooks table
+type webhookRecord struct {
+	ID int32 `db:"id"`
+	// Events is the comma separated event filter, empty for every event
+	Events    string    `db:"events"`
+	URL       string    `db:"url"`
+	Secret    string    `db:"secret"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func newWebhookRecord(w *user.Webhook) webhookRecord {
+	return webhookRecord{
+		URL:       w.Url,
+		Events:    strings.Join(w.Events, ","),
+		Secret:    w.Secret,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// wants reports whether the webhook subscribes to events of the type
+func (r webhookRecord) wants(eventType string) bool {
+	if r.Events == "" {
+		return true
+	}
+	for _, e := range strings.Split(r.Events, ",") {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// toWebhook returns the webhook held by the record, without its secret
+func (r webhookRecord) toWebhook() *user.Webhook {
+	w := &user.Webhook{Id: r.ID, Url: r.URL}
+	if r.Events != "" {
+		w.Events = strings.Split(r.Events, ",")
+	}
+	w.CreateTime, _ = ptypes.TimestampProto(r.CreatedAt)
+	return w
+}
+
+// deliveryRecord is a row of webhook_deliveries, joined with the webhook and event it delivers
+type deliveryRecord struct {
+	ID            int64     `db:"id"`
+	WebhookID     int32     `db:"webhook_id"`
+	EventID       int64     `db:"event_id"`
+	State         string    `db:"state"`
+	Attempts      int       `db:"attempts"`
+	LastError     string    `db:"last_error"`
+	NextAttemptAt time.Time `db:"next_attempt_at"`
+	CreatedAt     time.Time `db:"created_at"`
+	URL           string    `db:"url"`
+	Secret        string    `db:"secret"`
+	EventType     string    `db:"event_type"`
+	Payload       []byte    `db:"payload"`
+}
+
+// newDeliveryRecord returns a delivery of the event to the webhook, to be attempted straight away
+func newDeliveryRecord(webhookID int32, eventID int64) deliveryRecord {
+	now := time.Now().UTC()
+	return deliveryRecord{
+		WebhookID:     webhookID,
+		EventID:       eventID,
+		State:         DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
+
+// toDelivery returns the delivery to attempt
+func (r deliveryRecord) toDelivery() (Delivery, error) {
+	event, err := outboxRecord{ID: r.EventID, Payload: r.Payload}.toEvent()
+	if err != nil {
+		return Delivery{}, err
+	}
+	return Delivery{
+		ID:        r.ID,
+		WebhookID: r.WebhookID,
+		URL:       r.URL,
+		Secret:    r.Secret,
+		Attempts:  r.Attempts,
+		Event:     event,
+	}, nil
+}
+
+// toWebhookDelivery returns the delivery as it is listed
+func (r deliveryRecord) toWebhookDelivery() *user.WebhookDelivery {
+	d := &user.WebhookDelivery{
+		Id:        r.ID,
+		WebhookId: r.WebhookID,
+		EventId:   r.EventID,
+		EventType: r.EventType,
+		Attempts:  int32(r.Attempts),
+		LastError: r.LastError,
+	}
+	d.CreateTime, _ = ptypes.TimestampProto(r.CreatedAt)
+	return d
+}
+
+// deadLetters returns a page of at most size dead letters from the records, which may hold one more
+// record than the page to show that there is a following page
+func deadLetters(records []deliveryRecord, size int) *DeadLetters {
+	results := &DeadLetters{Deliveries: []*user.WebhookDelivery{}}
+	for i, r := range records {
+		if i == size {
+			results.NextToken = idToken(records[size-1].ID)
+			break
+		}
+		results.Deliveries = append(results.Deliveries, r.toWebhookDelivery())
+	}
+	return results
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	pb "github.com/beldin0/users/src/user"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// patternWatch matches GET /users:watch, as declared for the Watch RPC in user.proto
+var patternWatch = runtime.MustPattern(runtime.NewPattern(1, []int{2, 0}, []string{"users"}, "watch", runtime.AssumeColonVerbOpt(true)))
+
+// handleWatch serves the Watch RPC over HTTP, as the gateway does not support streaming in process.
+// Events are sent as newline delimited JSON in the gateway's streaming format, {"result": event},
+// or as server-sent events when the client accepts text/event-stream, which resume from Last-Event-ID.
+func handleWatch(mux *runtime.ServeMux, server pb.UserServiceServer) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		_, marshaler := runtime.MarshalerForRequest(mux, r)
+		ctx, err := runtime.AnnotateIncomingContext(r.Context(), mux, r)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, marshaler, w, r, err)
+			return
+		}
+		stream := &httpWatchStream{
+			ctx:       ctx,
+			w:         w,
+			marshaler: marshaler,
+			sse:       strings.Contains(r.Header.Get("Accept"), "text/event-stream"),
+		}
+		req := &pb.WatchRequest{ResumeToken: r.URL.Query().Get("resumeToken")}
+		if id := r.Header.Get("Last-Event-ID"); stream.sse && id != "" {
+			req.ResumeToken = id
+		}
+		if err := server.Watch(req, stream); err != nil {
+			if !stream.started {
+				runtime.HTTPError(ctx, mux, marshaler, w, r, err)
+				return
+			}
+			stream.sendError(err)
+		}
+	}
+}
+
+// httpWatchStream adapts an HTTP response to the server side of a Watch stream
+type httpWatchStream struct {
+	ctx       context.Context
+	w         http.ResponseWriter
+	marshaler runtime.Marshaler
+	// sse sends server-sent events rather than newline delimited JSON
+	sse     bool
+	started bool
+}
+
+func (s *httpWatchStream) Context() context.Context {
+	return s.ctx
+}
+
+// SendHeader starts the response, and is sent before the first event
+func (s *httpWatchStream) SendHeader(metadata.MD) error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	if s.sse {
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		s.w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	s.w.WriteHeader(http.StatusOK)
+	s.flush()
+	return nil
+}
+
+func (s *httpWatchStream) Send(e *pb.UserEvent) error {
+	if err := s.SendHeader(nil); err != nil {
+		return err
+	}
+	data, err := s.marshaler.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if s.sse {
+		_, err = fmt.Fprintf(s.w, "id: %s\ndata: %s\n\n", e.ResumeToken, data)
+	} else {
+		_, err = fmt.Fprintf(s.w, "{\"result\":%s}\n", data)
+	}
+	if err != nil {
+		return err
+	}
+	s.flush()
+	return nil
+}
+
+// sendError ends a started response with the status of the error
+func (s *httpWatchStream) sendError(err error) {
+	data, mErr := s.marshaler.Marshal(status.Convert(err).Proto())
+	if mErr != nil {
+		return
+	}
+	if s.sse {
+		fmt.Fprintf(s.w, "event: error\ndata: %s\n\n", data)
+	} else {
+		fmt.Fprintf(s.w, "{\"error\":%s}\n", data)
+	}
+	s.flush()
+}
+
+func (s *httpWatchStream) flush() {
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *httpWatchStream) SetHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *httpWatchStream) SetTrailer(metadata.MD) {}
+
+func (s *httpWatchStream) SendMsg(m interface{}) error {
+	e, ok := m.(*pb.UserEvent)
+	if !ok {
+		return fmt.Errorf("unexpected message %T on Watch stream", m)
+	}
+	return s.Send(e)
+}
+
+func (s *httpWatchStream) RecvMsg(interface{}) error {
+	return errors.New("Watch has no messages from the client")
+}